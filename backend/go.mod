@@ -2,12 +2,18 @@ module DevDesk
 
 go 1.24.5
 
-require github.com/gin-gonic/gin v1.11.0
+require (
+	github.com/alecthomas/chroma/v2 v2.20.0
+	github.com/gin-gonic/gin v1.11.0
+	github.com/pmezard/go-difflib v1.0.0
+	go.etcd.io/bbolt v1.4.0
+)
 
 require (
 	github.com/bytedance/sonic v1.14.0 // indirect
 	github.com/bytedance/sonic/loader v0.3.0 // indirect
 	github.com/cloudwego/base64x v0.1.6 // indirect
+	github.com/dlclark/regexp2 v1.11.5 // indirect
 	github.com/gabriel-vasile/mimetype v1.4.8 // indirect
 	github.com/gin-contrib/sse v1.1.0 // indirect
 	github.com/go-playground/locales v0.14.1 // indirect
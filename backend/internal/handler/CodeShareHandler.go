@@ -1,13 +1,26 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"DevDesk/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
+// adminTokenHeader 携带置顶所需的 admin token
+const adminTokenHeader = "X-CodeShare-Admin"
+
 type CodeShareHandler struct {
 	cs *service.CodeShare
 }
@@ -19,10 +32,26 @@ func NewCodeShareHandler(cs *service.CodeShare) *CodeShareHandler {
 // POST /codeshare/upload
 func (h *CodeShareHandler) Upload(c *gin.Context) {
 	var req struct {
-		Author   string `json:"author"   binding:"required"`
-		Language string `json:"language" binding:"required"`
-		Content  string `json:"content"  binding:"required"`
-		TTL      int64  `json:"ttl"`
+		Author string `json:"author"   binding:"required"`
+		// 为空或 auto 时根据内容和 filename 自动识别
+		Language string `json:"language"`
+		Filename string `json:"filename"`
+		Content  string `json:"content"`
+		// 多文件片段，与 content 二选一
+		Files []service.CodeFile `json:"files"`
+		// ttl 为秒数，缺省时使用默认值；expire 为预设（10m / 1h / 1d / 1w / never），优先于 ttl；
+		// 置顶（never）需开启置顶，配置了 admin token 时还需在 X-CodeShare-Admin 头中携带
+		TTL    int64  `json:"ttl"`
+		Expire string `json:"expire"`
+		// 客户端加密时携带，content 为 base64 密文
+		Encryption *service.CodeEncryption `json:"encryption"`
+		// 浏览次数上限；burn_after_read 等价于 max_views = 1
+		MaxViews      int  `json:"max_views"`
+		BurnAfterRead bool `json:"burn_after_read"`
+		// 对 Go 内容执行 gofmt，并在响应中返回语法 / 类型错误
+		Format bool `json:"format"`
+		// 公开片段出现在 /codeshare/list 中
+		Public bool `json:"public"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -32,29 +61,602 @@ func (h *CodeShareHandler) Upload(c *gin.Context) {
 		return
 	}
 
-	if req.TTL == 0 {
-		req.TTL = 3600
+	ttl, err := requestTTL(req.TTL, req.Expire)
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+	if req.BurnAfterRead {
+		req.MaxViews = 1
+	}
+
+	res, err := h.cs.Upload(&service.CodeUpload{
+		Author:     req.Author,
+		Language:   req.Language,
+		Filename:   req.Filename,
+		Content:    req.Content,
+		Files:      req.Files,
+		TTL:        ttl,
+		Encryption: req.Encryption,
+		MaxViews:   req.MaxViews,
+		FormatGo:   req.Format,
+		Public:     req.Public,
+		AdminToken: c.GetHeader(adminTokenHeader),
+	})
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, uploadResponse(res))
+}
+
+// POST /codeshare
+// 终端友好的上传：请求体即代码内容，参数放在 query 中，返回纯文本链接
+// 例：cat main.go | curl --data-binary @- "host/api/codeshare?language=go&ttl=600"
+func (h *CodeShareHandler) UploadRaw(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, service.MaxContentSize+1))
+	if err != nil {
+		c.String(http.StatusBadRequest, "read body: %s\n", err.Error())
+		return
+	}
+
+	ttl, err1 := strconv.ParseInt(c.DefaultQuery("ttl", "0"), 10, 64)
+	maxViews, err2 := strconv.Atoi(c.DefaultQuery("max_views", "0"))
+	burn, err3 := strconv.ParseBool(c.DefaultQuery("burn_after_read", "false"))
+	public, err4 := strconv.ParseBool(c.DefaultQuery("public", "false"))
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		c.String(http.StatusBadRequest, "ttl and max_views must be numbers, burn_after_read and public must be true or false\n")
+		return
+	}
+	ttl, err = requestTTL(ttl, c.Query("expire"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "%s\n", err.Error())
+		return
+	}
+	if burn {
+		maxViews = 1
+	}
+
+	res, err := h.cs.Upload(&service.CodeUpload{
+		Author:     c.DefaultQuery("author", "anonymous"),
+		Language:   c.DefaultQuery("language", service.LanguageAuto),
+		Filename:   c.Query("filename"),
+		Content:    string(body),
+		TTL:        ttl,
+		MaxViews:   maxViews,
+		Public:     public,
+		AdminToken: c.GetHeader(adminTokenHeader),
+	})
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, service.ErrCodeTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		case errors.Is(err, service.ErrCodePinDenied):
+			status = http.StatusForbidden
+		}
+		c.String(status, "%s\n", err.Error())
+		return
+	}
+
+	// 管理 token 放在响应头中，curl -i 可见
+	c.Header("X-CodeShare-Token", res.Token)
+	c.String(http.StatusOK, "%s/api/codeshare/raw/%s\n", baseURL(c), res.Code.Hash)
+}
+
+// GET /codeshare/raw/:hash?file=name
+func (h *CodeShareHandler) Raw(c *gin.Context) {
+	hash := c.Param("hash")
+
+	code, ok := h.cs.Get(hash)
+	if !ok {
+		c.String(http.StatusNotFound, "Code not found or expired\n")
+		return
+	}
+	if code.Encrypted() {
+		c.String(http.StatusUnprocessableEntity, "%s\n", service.ErrCodeEncrypted.Error())
+		return
+	}
+
+	// 多文件片段默认返回第一个文件
+	files := code.AllFiles()
+	file := files[0]
+	if name := c.Query("file"); name != "" {
+		found := false
+		for _, f := range files {
+			if f.Name == name {
+				file, found = f, true
+				break
+			}
+		}
+		if !found {
+			c.String(http.StatusNotFound, "File not found\n")
+			return
+		}
 	}
 
-	code := h.cs.Upload(req.Author, req.Language, req.Content, req.TTL)
-	if code == nil {
-		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
-			"error": "Content too large",
+	setExpires(c, code)
+	c.Header("X-Content-Type-Options", "nosniff")
+	if notModified(c, code, contentETag(file.Content)) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(file.Content))
+}
+
+// POST /codeshare/fork
+func (h *CodeShareHandler) Fork(c *gin.Context) {
+	var req struct {
+		Hash     string `json:"hash"   binding:"required"`
+		Author   string `json:"author" binding:"required"`
+		Language string `json:"language"`
+		// content 与 files 都为空时复制来源的当前版本
+		Content    string                  `json:"content"`
+		Files      []service.CodeFile      `json:"files"`
+		Encryption *service.CodeEncryption `json:"encryption"`
+		TTL        int64                   `json:"ttl"`
+		Expire     string                  `json:"expire"`
+		Public     bool                    `json:"public"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid JSON: " + err.Error(),
 		})
 		return
 	}
 
+	ttl, err := requestTTL(req.TTL, req.Expire)
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+
+	res, err := h.cs.Fork(req.Hash, &service.CodeUpload{
+		Author:     req.Author,
+		Language:   req.Language,
+		Content:    req.Content,
+		Files:      req.Files,
+		TTL:        ttl,
+		Encryption: req.Encryption,
+		Public:     req.Public,
+		AdminToken: c.GetHeader(adminTokenHeader),
+	})
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+
+	resp := uploadResponse(res)
+	resp["parent"] = res.Code.Parent
+	c.JSON(http.StatusOK, resp)
+}
+
+// POST /codeshare/revise
+func (h *CodeShareHandler) Revise(c *gin.Context) {
+	var req struct {
+		Hash       string                  `json:"hash"    binding:"required"`
+		Token      string                  `json:"token"   binding:"required"`
+		Language   string                  `json:"language"`
+		Content    string                  `json:"content"`
+		Files      []service.CodeFile      `json:"files"`
+		Encryption *service.CodeEncryption `json:"encryption"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid JSON: " + err.Error(),
+		})
+		return
+	}
+
+	code, err := h.cs.Revise(req.Hash, req.Token, &service.CodeRevise{
+		Language:   req.Language,
+		Content:    req.Content,
+		Files:      req.Files,
+		Encryption: req.Encryption,
+	})
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "success",
-		"hash":    code.Hash,
-		"url":     "/codeshare/code/" + code.Hash,
+		"message":  "success",
+		"hash":     code.Hash,
+		"revision": code.Revision,
 	})
 }
 
+// POST /codeshare/delete
+func (h *CodeShareHandler) Delete(c *gin.Context) {
+	var req struct {
+		Hash  string `json:"hash"  binding:"required"`
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid JSON: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.cs.Delete(req.Hash, req.Token); err != nil {
+		codeShareError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// POST /codeshare/update
+// content / files 非空时发布新版本，ttl 非空时从现在起重新计算过期时间
+func (h *CodeShareHandler) Update(c *gin.Context) {
+	var req struct {
+		Hash       string                  `json:"hash"  binding:"required"`
+		Token      string                  `json:"token" binding:"required"`
+		Language   string                  `json:"language"`
+		Content    string                  `json:"content"`
+		Files      []service.CodeFile      `json:"files"`
+		Encryption *service.CodeEncryption `json:"encryption"`
+		TTL        *int64                  `json:"ttl"`
+		Expire     string                  `json:"expire"`
+		Public     *bool                   `json:"public"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid JSON: " + err.Error(),
+		})
+		return
+	}
+
+	if req.Expire != "" {
+		ttl, err := service.ParseTTLPreset(req.Expire)
+		if err != nil {
+			codeShareError(c, err)
+			return
+		}
+		req.TTL = &ttl
+	}
+
+	u := &service.CodeUpdate{TTL: req.TTL, Public: req.Public, AdminToken: c.GetHeader(adminTokenHeader)}
+	if req.Content != "" || len(req.Files) > 0 {
+		u.Revise = &service.CodeRevise{
+			Language:   req.Language,
+			Content:    req.Content,
+			Files:      req.Files,
+			Encryption: req.Encryption,
+		}
+	}
+
+	code, err := h.cs.Update(req.Hash, req.Token, u)
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "success",
+		"hash":         code.Hash,
+		"revision":     code.Revision,
+		"destroy_time": code.DestroyTime,
+		"public":       code.Public,
+	})
+}
+
+// POST /codeshare/extend
+// 在剩余有效期上增加 ttl 秒（或 expire 预设），expire 为 never 时置顶
+func (h *CodeShareHandler) Extend(c *gin.Context) {
+	var req struct {
+		Hash   string `json:"hash"  binding:"required"`
+		Token  string `json:"token" binding:"required"`
+		TTL    int64  `json:"ttl"`
+		Expire string `json:"expire"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid JSON: " + err.Error(),
+		})
+		return
+	}
+
+	ttl, err := requestTTL(req.TTL, req.Expire)
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+
+	code, err := h.cs.Extend(req.Hash, req.Token, ttl, c.GetHeader(adminTokenHeader))
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "success",
+		"hash":         code.Hash,
+		"destroy_time": code.DestroyTime,
+		"pinned":       code.Pinned(),
+	})
+}
+
+// GET /codeshare/ttl
+func (h *CodeShareHandler) TTLOptions(c *gin.Context) {
+	policy := h.cs.TTLPolicy()
+	c.JSON(http.StatusOK, gin.H{
+		"policy":  policy,
+		"presets": policy.Presets(),
+	})
+}
+
+// GET /codeshare/revisions/:hash
+func (h *CodeShareHandler) Revisions(c *gin.Context) {
+	hash := c.Param("hash")
+
+	code, ok := h.cs.Revisions(hash)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Code not found or expired",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hash":            code.Hash,
+		"revision":        code.Revision,
+		"parent":          code.Parent,
+		"parent_revision": code.ParentRevision,
+		"revisions":       code.RevisionInfos(),
+	})
+}
+
+// GET /codeshare/diff/:hash?from=1&to=2
+func (h *CodeShareHandler) Diff(c *gin.Context) {
+	hash := c.Param("hash")
+
+	from, err1 := strconv.Atoi(c.Query("from"))
+	to, err2 := strconv.Atoi(c.DefaultQuery("to", "0"))
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from and to must be revision numbers",
+		})
+		return
+	}
+
+	diff, err := h.cs.Diff(hash, from, to)
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/x-diff; charset=utf-8", []byte(diff))
+}
+
 // GET /codeshare/code/:hash
 func (h *CodeShareHandler) Get(c *gin.Context) {
 	hash := c.Param("hash")
 
+	// ?rev=N 查看指定历史版本
+	n := 0
+	if rev := c.Query("rev"); rev != "" {
+		var err error
+		if n, err = strconv.Atoi(rev); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rev must be a number"})
+			return
+		}
+	}
+
+	code, err := h.cs.GetRevision(hash, n)
+	if errors.Is(err, service.ErrCodeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Code not found or expired",
+		})
+		return
+	}
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+
+	setExpires(c, code)
+	if notModified(c, code, codeETag(code)) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.JSON(http.StatusOK, code)
+}
+
+// GET /codeshare/list?q=&language=&author=&since=&until=&page=1&size=20
+// since / until 可为 unix 秒、RFC3339 或 2006-01-02
+func (h *CodeShareHandler) List(c *gin.Context) {
+	q := service.CodeQuery{
+		Language: c.Query("language"),
+		Author:   c.Query("author"),
+		Query:    c.Query("q"),
+	}
+	var err error
+	if q.Since, err = parseTimeParam(c.Query("since")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since: " + err.Error()})
+		return
+	}
+	if q.Until, err = parseTimeParam(c.Query("until")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid until: " + err.Error()})
+		return
+	}
+	q.Page, _ = strconv.Atoi(c.Query("page"))
+	q.Size, _ = strconv.Atoi(c.Query("size"))
+
+	list, err := h.cs.List(q)
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GET /codeshare/comments/:hash
+func (h *CodeShareHandler) Comments(c *gin.Context) {
+	threads, err := h.cs.Comments(c.Param("hash"))
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"comments": threads})
+}
+
+// POST /codeshare/comment
+// 新评论需给出行区间（多文件片段还需 file）；回复只需 parent_id
+func (h *CodeShareHandler) AddComment(c *gin.Context) {
+	var req struct {
+		Hash      string `json:"hash"   binding:"required"`
+		Author    string `json:"author" binding:"required"`
+		Body      string `json:"body"   binding:"required"`
+		File      string `json:"file"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+		ParentID  int    `json:"parent_id"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid JSON: " + err.Error(),
+		})
+		return
+	}
+
+	cm, err := h.cs.AddComment(req.Hash, &service.CommentInput{
+		Author:    req.Author,
+		Body:      req.Body,
+		File:      req.File,
+		StartLine: req.StartLine,
+		EndLine:   req.EndLine,
+		ParentID:  req.ParentID,
+	})
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cm)
+}
+
+// POST /codeshare/comment/resolve
+// resolved 缺省为 true，传 false 重新打开讨论串
+func (h *CodeShareHandler) ResolveComment(c *gin.Context) {
+	var req struct {
+		Hash     string `json:"hash" binding:"required"`
+		ID       int    `json:"id"   binding:"required"`
+		Resolved *bool  `json:"resolved"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid JSON: " + err.Error(),
+		})
+		return
+	}
+
+	resolved := req.Resolved == nil || *req.Resolved
+	cm, err := h.cs.ResolveComment(req.Hash, req.ID, resolved)
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cm)
+}
+
+// GET /codeshare/comments/:hash/stream
+// SSE：event 为 comment / resolved，data 为对应评论
+func (h *CodeShareHandler) StreamComments(c *gin.Context) {
+	hash := c.Param("hash")
+
+	w := c.Writer
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "streaming unsupported"})
+		return
+	}
+
+	ch, err := h.cs.SubscribeComments(hash)
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+	defer h.cs.UnsubscribeComments(hash, ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	flusher.Flush()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(ev.Comment)
+			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// GET /codeshare/render/:hash?theme=github&lines=1
+func (h *CodeShareHandler) Render(c *gin.Context) {
+	hash := c.Param("hash")
+
+	// 渲染页常被链接预览抓取，不计入浏览
+	code, err := h.cs.Peek(hash)
+	switch {
+	case errors.Is(err, service.ErrCodeViewLimited):
+		c.String(http.StatusForbidden, "Code with a view limit can only be viewed in the browser")
+		return
+	case err != nil:
+		c.String(http.StatusNotFound, "Code not found or expired")
+		return
+	}
+
+	page, err := service.RenderCodeHTML(code, service.RenderOptions{
+		Theme:       c.DefaultQuery("theme", service.DefaultRenderTheme),
+		LineNumbers: c.DefaultQuery("lines", "1") != "0" && c.Query("lines") != "false",
+	})
+	if errors.Is(err, service.ErrCodeEncrypted) {
+		c.String(http.StatusUnprocessableEntity, "Encrypted code can only be viewed in the browser")
+		return
+	}
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setExpires(c, code)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+}
+
+// GET /codeshare/archive/:hash?format=zip|tar.gz
+func (h *CodeShareHandler) Archive(c *gin.Context) {
+	hash := c.Param("hash")
+	format := c.DefaultQuery("format", service.ArchiveZip)
+
+	contentType := "application/zip"
+	switch format {
+	case service.ArchiveZip:
+	case service.ArchiveTarGz:
+		contentType = "application/gzip"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrArchiveFormat.Error()})
+		return
+	}
+
 	code, ok := h.cs.Get(hash)
 	if !ok {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -62,6 +664,215 @@ func (h *CodeShareHandler) Get(c *gin.Context) {
 		})
 		return
 	}
+	if code.Encrypted() {
+		codeShareError(c, service.ErrCodeEncrypted)
+		return
+	}
 
-	c.JSON(http.StatusOK, code)
+	var buf bytes.Buffer
+	if err := service.WriteArchive(&buf, code, format); err != nil {
+		codeShareError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, code.Hash, format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// POST /codeshare/gocheck
+// 传 content 检查任意 Go 源码，或传 hash 检查已分享片段中的 Go 文件
+func (h *CodeShareHandler) GoCheck(c *gin.Context) {
+	var req struct {
+		Hash      string `json:"hash"`
+		Content   string `json:"content"`
+		TypeCheck *bool  `json:"type_check"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid JSON: " + err.Error(),
+		})
+		return
+	}
+
+	typeCheck := req.TypeCheck == nil || *req.TypeCheck
+
+	if req.Hash == "" {
+		if len(req.Content) > service.MaxContentSize {
+			codeShareError(c, service.ErrCodeTooLarge)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"results": []*service.GoCheckResult{service.CheckGo(req.Content, typeCheck)},
+		})
+		return
+	}
+
+	code, err := h.cs.Peek(req.Hash)
+	if err != nil {
+		codeShareError(c, err)
+		return
+	}
+	if code.Encrypted() {
+		codeShareError(c, service.ErrCodeEncrypted)
+		return
+	}
+
+	results := []*service.GoCheckResult{}
+	for _, f := range code.AllFiles() {
+		if !service.IsGo(f.Language) {
+			continue
+		}
+		r := service.CheckGo(f.Content, typeCheck)
+		if code.IsMultiFile() {
+			r.File = f.Name
+		}
+		results = append(results, r)
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// GET /codeshare/themes
+func (h *CodeShareHandler) Themes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"default": service.DefaultRenderTheme,
+		"themes":  service.RenderThemes(),
+	})
+}
+
+// GET /codeshare/stats
+func (h *CodeShareHandler) Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.cs.Stats())
+}
+
+func uploadResponse(res *service.UploadResult) gin.H {
+	resp := gin.H{
+		"message":  "success",
+		"hash":     res.Code.Hash,
+		"url":      "/codeshare/code/" + res.Code.Hash,
+		"language": res.Code.Language,
+		// 管理 token 只返回这一次，用于删除、修改内容或有效期
+		"token": res.Token,
+	}
+	if len(res.Detected) > 0 {
+		resp["detected"] = res.Detected
+	}
+	if len(res.GoChecks) > 0 {
+		resp["go_check"] = res.GoChecks
+	}
+	return resp
+}
+
+// 基于内容摘要的强 ETag
+func contentETag(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// requestTTL 合并 ttl 与 expire 预设，expire 优先
+func requestTTL(ttl int64, expire string) (int64, error) {
+	if expire != "" {
+		return service.ParseTTLPreset(expire)
+	}
+	return ttl, nil
+}
+
+// setExpires 根据 DestroyTime 设置 Expires 头，置顶片段不设置
+func setExpires(c *gin.Context, code *service.Code) {
+	if code.Pinned() {
+		return
+	}
+	c.Header("Expires", time.Unix(code.DestroyTime, 0).UTC().Format(http.TimeFormat))
+}
+
+// parseTimeParam 解析 unix 秒、RFC3339 或日期，空字符串返回 0
+func parseTimeParam(v string) (int64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.Unix(), nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+	if err != nil {
+		return 0, errors.New("expected unix seconds, RFC3339 or YYYY-MM-DD")
+	}
+	return t.Unix(), nil
+}
+
+// codeETag 由正文摘要和响应中会变化的元数据组成
+func codeETag(code *service.Code) string {
+	digest := code.Digest
+	if len(digest) > 32 {
+		digest = digest[:32]
+	}
+	return fmt.Sprintf(`"%s-%d-%d-%d"`, digest, code.Revision, code.DestroyTime, code.Views)
+}
+
+// notModified 设置 ETag 并判断条件请求是否命中。有浏览上限的片段在读取时已计入浏览，
+// 不提供 ETag 与 304，否则重新验证会消耗次数（甚至删除片段）却拿不到内容
+func notModified(c *gin.Context, code *service.Code, etag string) bool {
+	if code.MaxViews > 0 {
+		c.Header("Cache-Control", "no-store")
+		return false
+	}
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	return ifNoneMatch(c.GetHeader("If-None-Match"), etag)
+}
+
+// ifNoneMatch 判断 If-None-Match 是否命中 etag（支持列表和 *）
+func ifNoneMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// 将 service 层错误映射为 HTTP 状态码
+func codeShareError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrCodeNotFound),
+		errors.Is(err, service.ErrCodeRevisionNotFound),
+		errors.Is(err, service.ErrCommentNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrCodeTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrCodeForbidden),
+		errors.Is(err, service.ErrCodeViewLimited),
+		errors.Is(err, service.ErrCodePinDenied):
+		status = http.StatusForbidden
+	case errors.Is(err, service.ErrCodeEncrypted):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrCodePinLimit):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrCodeCipherInvalid),
+		errors.Is(err, service.ErrCodeEmpty),
+		errors.Is(err, service.ErrCodeFileName),
+		errors.Is(err, service.ErrCodeTooManyFiles),
+		errors.Is(err, service.ErrCodeEncryptedFiles),
+		errors.Is(err, service.ErrCodeInvalidTTL),
+		errors.Is(err, service.ErrCodeTTLRange),
+		errors.Is(err, service.ErrCodeTTLPreset),
+		errors.Is(err, service.ErrArchiveFormat),
+		errors.Is(err, service.ErrCodeInvalidViews),
+		errors.Is(err, service.ErrCodeTooManyRevisions),
+		errors.Is(err, service.ErrCodeEncryptionMode),
+		errors.Is(err, service.ErrCommentEmpty),
+		errors.Is(err, service.ErrCommentTooLarge),
+		errors.Is(err, service.ErrCommentTooMany),
+		errors.Is(err, service.ErrCommentRange):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
 }
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"DevDesk/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestCodeShare(t *testing.T) (*service.CodeShare, http.Handler) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cs, err := service.NewCodeShareService(service.CodeShareConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewCodeShareHandler(cs)
+	r := gin.New()
+	r.GET("/codeshare/raw/:hash", h.Raw)
+	r.GET("/codeshare/code/:hash", h.Get)
+	r.GET("/codeshare/revisions/:hash", h.Revisions)
+	r.GET("/codeshare/diff/:hash", h.Diff)
+	return cs, r
+}
+
+func TestConditionalGetViews(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		maxViews int
+		// 每次请求都带 If-None-Match: *，依次期望的状态码
+		want []int
+	}{
+		{name: "raw unlimited revalidates", path: "/codeshare/raw/", want: []int{http.StatusNotModified, http.StatusNotModified}},
+		{name: "code unlimited revalidates", path: "/codeshare/code/", want: []int{http.StatusNotModified, http.StatusNotModified}},
+		// 有浏览上限时每次请求都返回内容，不会出现消耗了次数却只得到 304
+		{name: "raw view limited", path: "/codeshare/raw/", maxViews: 2, want: []int{http.StatusOK, http.StatusOK, http.StatusNotFound}},
+		{name: "code burn after read", path: "/codeshare/code/", maxViews: 1, want: []int{http.StatusOK, http.StatusNotFound}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, r := newTestCodeShare(t)
+			res, err := cs.Upload(&service.CodeUpload{Author: "a", Language: "go", Content: "package main", MaxViews: tt.maxViews})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, want := range tt.want {
+				req := httptest.NewRequest(http.MethodGet, tt.path+res.Code.Hash, nil)
+				req.Header.Set("If-None-Match", "*")
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				if w.Code != want {
+					t.Fatalf("request %d: status = %d, want %d", i, w.Code, want)
+				}
+				if want == http.StatusOK && w.Body.Len() == 0 {
+					t.Errorf("request %d: empty body", i)
+				}
+				if tt.maxViews > 0 && w.Header().Get("ETag") != "" {
+					t.Errorf("request %d: view-limited code has an ETag", i)
+				}
+			}
+
+			// 304 不计入浏览
+			if tt.maxViews == 0 {
+				code, err := cs.Peek(res.Code.Hash)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if code.Views != 0 {
+					t.Errorf("views = %d after 304, want 0", code.Views)
+				}
+			}
+		})
+	}
+}
+
+func TestRevisionHistory(t *testing.T) {
+	cs, r := newTestCodeShare(t)
+	res, err := cs.Upload(&service.CodeUpload{Author: "a", Language: "go", Content: "package v1\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := res.Code.Hash
+	if _, err := cs.Revise(hash, res.Token, &service.CodeRevise{Content: "package v2\n"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 历史版本的正文按需读取
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{path: "/codeshare/code/" + hash + "?rev=1", status: http.StatusOK, want: "package v1"},
+		{path: "/codeshare/code/" + hash, status: http.StatusOK, want: "package v2"},
+		{path: "/codeshare/code/" + hash + "?rev=3", status: http.StatusNotFound},
+		{path: "/codeshare/revisions/" + hash, status: http.StatusOK, want: `"size":11`},
+		{path: "/codeshare/diff/" + hash + "?from=1&to=2", status: http.StatusOK, want: "+package v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("body = %s, want %q", w.Body.String(), tt.want)
+			}
+		})
+	}
+}
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
 	"strings"
 
@@ -50,11 +49,7 @@ func (h *HtmlHostHandler) Upload(c *gin.Context) {
 	relative := strings.TrimRight(h.publicPrefix, "/") + "/" + filename
 	sharePath := "/api" + relative
 
-	scheme := "http"
-	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
-		scheme = "https"
-	}
-	fullURL := fmt.Sprintf("%s://%s%s", scheme, c.Request.Host, sharePath)
+	fullURL := baseURL(c) + sharePath
 
 	c.JSON(http.StatusOK, gin.H{
 		"url":         sharePath,
@@ -1,7 +1,14 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"DevDesk/internal/service"
 
@@ -22,33 +29,64 @@ func NewWorkPlanHandler(srv *service.WorkPlan) *WorkPlanHandler {
 func (h *WorkPlanHandler) NewPersonlPlan(c *gin.Context) {
 	pp := h.wp.NewPersonalPlan()
 	c.JSON(http.StatusOK, gin.H{
-		"hash": pp.Hash,
+		"hash":      pp.Hash,
+		"view_hash": pp.ViewHash,
+		// 订阅地址使用只读 hash，可以放心分享
+		"ics": baseURL(c) + "/api/workplan/ics/" + pp.ViewHash + ".ics",
 	})
 }
 
+// editPlan 按编辑 hash 查找计划，只读 hash 返回 403；返回 nil 时已写入错误响应
+func (h *WorkPlanHandler) editPlan(c *gin.Context, hash string) *service.PersonalPlan {
+	pp, err := h.wp.EditPlan(hash)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrPlanReadOnly) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return nil
+	}
+	return pp
+}
+
 // 添加 TODO
 func (h *WorkPlanHandler) AddTODO(c *gin.Context) {
 	var req struct {
 		Hash    string `json:"hash"`
 		Content string `json:"content"`
+		// 父任务、截止时间（unix 秒）、优先级（0-3）、标签、重复规则均可选
+		ParentID *int      `json:"parent_id"`
+		Due      *int64    `json:"due"`
+		Priority *int      `json:"priority"`
+		Tags     *[]string `json:"tags"`
+		Repeat   *string   `json:"repeat"`
+		Assignee *int      `json:"assignee"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	pp := h.wp.GetPlan(req.Hash)
+	pp := h.editPlan(c, req.Hash)
 	if pp == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "plan not found"})
 		return
 	}
 
-	if err := pp.AddTODO(req.Content); err != nil {
+	todo, err := pp.AddTODO(req.Content, service.TODOFields{
+		ParentID: req.ParentID,
+		Due:      req.Due,
+		Priority: req.Priority,
+		Tags:     req.Tags,
+		Repeat:   req.Repeat,
+		Assignee: req.Assignee,
+	})
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"ok": true})
+	c.JSON(http.StatusOK, gin.H{"ok": true, "todo": todo})
 }
 
 // 删除 TODO
@@ -62,9 +100,8 @@ func (h *WorkPlanHandler) DeleteTODO(c *gin.Context) {
 		return
 	}
 
-	pp := h.wp.GetPlan(req.Hash)
+	pp := h.editPlan(c, req.Hash)
 	if pp == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "plan not found"})
 		return
 	}
 
@@ -76,25 +113,38 @@ func (h *WorkPlanHandler) DeleteTODO(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
-// 修改 TODO 内容
+// 修改 TODO 内容；未提供的字段保持不变，due 为 0 清除截止时间，tags 为 [] 清空标签，parent_id 为 0 移到顶层
 func (h *WorkPlanHandler) EditTODO(c *gin.Context) {
 	var req struct {
-		Hash    string `json:"hash"`
-		Id      int    `json:"id"`
-		Content string `json:"content"`
+		Hash     string    `json:"hash"`
+		Id       int       `json:"id"`
+		Content  string    `json:"content"`
+		ParentID *int      `json:"parent_id"`
+		Due      *int64    `json:"due"`
+		Priority *int      `json:"priority"`
+		Tags     *[]string `json:"tags"`
+		Repeat   *string   `json:"repeat"`
+		Assignee *int      `json:"assignee"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	pp := h.wp.GetPlan(req.Hash)
+	pp := h.editPlan(c, req.Hash)
 	if pp == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "plan not found"})
 		return
 	}
 
-	if err := pp.EditTODO(req.Id, req.Content); err != nil {
+	err := pp.EditTODO(req.Id, req.Content, service.TODOFields{
+		ParentID: req.ParentID,
+		Due:      req.Due,
+		Priority: req.Priority,
+		Tags:     req.Tags,
+		Repeat:   req.Repeat,
+		Assignee: req.Assignee,
+	})
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -113,13 +163,39 @@ func (h *WorkPlanHandler) SetDone(c *gin.Context) {
 		return
 	}
 
-	pp := h.wp.GetPlan(req.Hash)
+	pp := h.editPlan(c, req.Hash)
 	if pp == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "plan not found"})
 		return
 	}
 
-	if err := pp.SetTODODone(req.Id); err != nil {
+	res, err := pp.SetTODODone(req.Id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 完成重复任务时返回自动创建的下一次实例；skipped 为因 TODO 数量上限没有生成下一次实例的任务
+	c.JSON(http.StatusOK, gin.H{"ok": true, "next": res.Next, "skipped": res.Skipped})
+}
+
+// 调整 TODO 顺序：移动到 position（从 0 开始）
+func (h *WorkPlanHandler) MoveTODO(c *gin.Context) {
+	var req struct {
+		Hash     string `json:"hash"`
+		Id       int    `json:"id"`
+		Position int    `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pp := h.editPlan(c, req.Hash)
+	if pp == nil {
+		return
+	}
+
+	if err := pp.MoveTODO(req.Id, req.Position); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -128,16 +204,506 @@ func (h *WorkPlanHandler) SetDone(c *gin.Context) {
 }
 
 // 获取 TODO 列表
+// ?tag=a&tag=b（或 tag=a,b）&status=all|open|done&due_from=&due_to=&assignee=<成员 ID>|none&sort=manual|priority|due|created
 func (h *WorkPlanHandler) GetTODOs(c *gin.Context) {
 	hash := c.Param("hash")
 
-	pp := h.wp.GetPlan(hash)
+	pp, editable := h.wp.ViewPlan(hash)
 	if pp == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "plan not found"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrPlanNotFound.Error()})
+		return
+	}
+
+	q := service.TODOQuery{
+		Status: c.Query("status"),
+		Sort:   c.Query("sort"),
+	}
+	for _, v := range c.QueryArray("tag") {
+		for _, tag := range strings.Split(v, ",") {
+			if tag != "" {
+				q.Tags = append(q.Tags, tag)
+			}
+		}
+	}
+	var err error
+	if q.DueFrom, err = parseTimeParam(c.Query("due_from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid due_from: " + err.Error()})
+		return
+	}
+	if q.DueTo, err = parseTimeParam(c.Query("due_to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid due_to: " + err.Error()})
+		return
+	}
+	switch a := c.Query("assignee"); a {
+	case "":
+	case "none":
+		q.Unassigned = true
+	default:
+		if q.Assignee, err = strconv.Atoi(a); err != nil || q.Assignee <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "assignee must be a member id or none"})
+			return
+		}
+	}
+
+	todos, err := pp.QueryTODOs(q)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"todos": pp.GetTODOs(),
+		"todos":     todos,
+		"tree":      pp.Tree(todos),
+		"auto_done": pp.AutoDoneEnabled(),
+		"members":   pp.Members(),
+		"view_hash": pp.ViewHash,
+		// 为 false 时使用的是只读 hash，前端应隐藏编辑操作
+		"editable": editable,
 	})
 }
+
+// 修改计划设置：auto_done 为 true 时子任务全部完成会自动完成父任务
+func (h *WorkPlanHandler) UpdateSettings(c *gin.Context) {
+	var req struct {
+		Hash     string `json:"hash"`
+		AutoDone *bool  `json:"auto_done"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pp := h.editPlan(c, req.Hash)
+	if pp == nil {
+		return
+	}
+
+	if req.AutoDone != nil {
+		pp.SetAutoDone(*req.AutoDone)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// 实时同步：连接后先推送 snapshot，之后推送每次修改（add / edit / delete / done / move / settings）
+func (h *WorkPlanHandler) StreamPlan(c *gin.Context) {
+	pp, _ := h.wp.ViewPlan(c.Param("hash"))
+	if pp == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": service.ErrPlanNotFound.Error()})
+		return
+	}
+	w := c.Writer
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "streaming unsupported"})
+		return
+	}
+
+	ch := pp.AddClient()
+	defer pp.RemoveClient(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	flusher.Flush()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(ev)
+			_, _ = w.Write([]byte("data: " + string(data) + "\n\n"))
+			flusher.Flush()
+		}
+	}
+}
+
+// 导出 iCalendar 订阅源，日历应用可直接订阅 /api/workplan/ics/<hash>.ics
+func (h *WorkPlanHandler) ExportICS(c *gin.Context) {
+	hash := strings.TrimSuffix(c.Param("hash"), ".ics")
+
+	pp, _ := h.wp.ViewPlan(hash)
+	if pp == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": service.ErrPlanNotFound.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Content-Disposition", `inline; filename="workplan-`+pp.ViewHash+`.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", pp.ICS())
+}
+
+// 导入 .ics 文件：multipart 表单的 file 字段，或直接以请求体上传
+func (h *WorkPlanHandler) ImportICS(c *gin.Context) {
+	h.importPlan(c, service.FormatICS)
+}
+
+// workPlanFormats 为各导出格式的 Content-Type 与扩展名
+var workPlanFormats = map[string]struct{ contentType, ext string }{
+	service.FormatMarkdown: {"text/markdown; charset=utf-8", "md"},
+	service.FormatTodoTxt:  {"text/plain; charset=utf-8", "txt"},
+	service.FormatCSV:      {"text/csv; charset=utf-8", "csv"},
+	service.FormatJSON:     {"application/json; charset=utf-8", "json"},
+	service.FormatICS:      {"text/calendar; charset=utf-8", "ics"},
+}
+
+// 导出计划：GET /workplan/export/:hash?format=md|todotxt|csv|json|ics
+func (h *WorkPlanHandler) Export(c *gin.Context) {
+	pp, _ := h.wp.ViewPlan(c.Param("hash"))
+	if pp == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": service.ErrPlanNotFound.Error()})
+		return
+	}
+
+	format := c.Query("format")
+	f, ok := workPlanFormats[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrPlanFormat.Error()})
+		return
+	}
+	data, err := pp.Export(format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="workplan-`+pp.ViewHash+"."+f.ext+`"`)
+	c.Data(http.StatusOK, f.contentType, data)
+}
+
+// 导入到计划：POST /workplan/import/:hash?format=，未指定格式时按上传文件的扩展名推断
+func (h *WorkPlanHandler) Import(c *gin.Context) {
+	h.importPlan(c, c.Query("format"))
+}
+
+// importPlan 读取 multipart 表单的 file 字段或请求体并导入
+func (h *WorkPlanHandler) importPlan(c *gin.Context, format string) {
+	pp := h.editPlan(c, c.Param("hash"))
+	if pp == nil {
+		return
+	}
+
+	body := c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		if format == "" {
+			format = service.FormatFromFilename(fileHeader.Filename)
+		}
+		f, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+	data, err := io.ReadAll(io.LimitReader(body, service.MaxImportSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := pp.Import(format, data)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrImportTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "imported": len(res.TODOs), "skipped": res.Skipped, "todos": res.TODOs})
+}
+
+// 重复任务的完成历史：GET /workplan/history/:hash?id=
+func (h *WorkPlanHandler) History(c *gin.Context) {
+	pp, _ := h.wp.ViewPlan(c.Param("hash"))
+	if pp == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrPlanNotFound.Error()})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		return
+	}
+	todos, err := pp.History(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"todos": todos})
+}
+
+// 添加成员
+func (h *WorkPlanHandler) AddMember(c *gin.Context) {
+	var req struct {
+		Hash string `json:"hash"`
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pp := h.editPlan(c, req.Hash)
+	if pp == nil {
+		return
+	}
+
+	m, err := pp.AddMember(req.Name)
+	if err != nil {
+		c.JSON(workPlanMemberStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "member": m})
+}
+
+// 修改成员名字
+func (h *WorkPlanHandler) RenameMember(c *gin.Context) {
+	var req struct {
+		Hash string `json:"hash"`
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pp := h.editPlan(c, req.Hash)
+	if pp == nil {
+		return
+	}
+
+	if err := pp.RenameMember(req.Id, req.Name); err != nil {
+		c.JSON(workPlanMemberStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// 删除成员，其负责的 TODO 变为未分配
+func (h *WorkPlanHandler) RemoveMember(c *gin.Context) {
+	var req struct {
+		Hash string `json:"hash"`
+		Id   int    `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pp := h.editPlan(c, req.Hash)
+	if pp == nil {
+		return
+	}
+
+	if err := pp.RemoveMember(req.Id); err != nil {
+		c.JSON(workPlanMemberStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func workPlanMemberStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrMemberNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrMemberExists), errors.Is(err, service.ErrMemberTooMany):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// 活动记录：GET /workplan/activity/:hash?limit=&before=<seq>，新的在前
+func (h *WorkPlanHandler) Activity(c *gin.Context) {
+	pp, _ := h.wp.ViewPlan(c.Param("hash"))
+	if pp == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrPlanNotFound.Error()})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > service.MaxPlanActivity {
+		limit = 50
+	}
+	before, _ := strconv.Atoi(c.Query("before"))
+
+	undo, redo := pp.UndoDepth()
+	c.JSON(http.StatusOK, gin.H{
+		"activity": pp.Activity(before, limit),
+		"can_undo": undo,
+		"can_redo": redo,
+	})
+}
+
+// 撤销最近的 n 个操作
+func (h *WorkPlanHandler) Undo(c *gin.Context) {
+	h.undoRedo(c, (*service.PersonalPlan).Undo)
+}
+
+// 重做最近撤销的 n 个操作
+func (h *WorkPlanHandler) Redo(c *gin.Context) {
+	h.undoRedo(c, (*service.PersonalPlan).Redo)
+}
+
+func (h *WorkPlanHandler) undoRedo(c *gin.Context, step func(*service.PersonalPlan, int) ([]service.Activity, error)) {
+	var req struct {
+		Hash string `json:"hash"`
+		// N 默认为 1
+		N int `json:"n"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.N == 0 {
+		req.N = 1
+	}
+	if req.N < 0 || req.N > service.MaxPlanUndo {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("n must be between 1 and %d", service.MaxPlanUndo)})
+		return
+	}
+
+	pp := h.editPlan(c, req.Hash)
+	if pp == nil {
+		return
+	}
+
+	reverted, err := step(pp, req.N)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	undo, redo := pp.UndoDepth()
+	c.JSON(http.StatusOK, gin.H{"ok": true, "activity": reverted, "can_undo": undo, "can_redo": redo})
+}
+
+// 开始计时，pomodoro 不为空时使用番茄钟（时间单位为秒，{} 使用默认的 25 / 5 分钟 4 轮）
+func (h *WorkPlanHandler) StartTimer(c *gin.Context) {
+	var req struct {
+		Hash     string            `json:"hash"`
+		TODOID   int               `json:"todo_id"`
+		Member   int               `json:"member"`
+		Pomodoro *service.Pomodoro `json:"pomodoro"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pp := h.editPlan(c, req.Hash)
+	if pp == nil {
+		return
+	}
+
+	started, stopped, err := pp.StartTimer(req.TODOID, req.Member, req.Pomodoro)
+	if err != nil {
+		c.JSON(workPlanTimerStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// stopped 为被自动停止的上一个计时
+	c.JSON(http.StatusOK, gin.H{"ok": true, "session": started, "stopped": stopped})
+}
+
+// 停止计时，todo_id 为 0 时停止该成员的任意计时
+func (h *WorkPlanHandler) StopTimer(c *gin.Context) {
+	var req struct {
+		Hash   string `json:"hash"`
+		TODOID int    `json:"todo_id"`
+		Member int    `json:"member"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pp := h.editPlan(c, req.Hash)
+	if pp == nil {
+		return
+	}
+
+	session, err := pp.StopTimer(req.TODOID, req.Member)
+	if err != nil {
+		c.JSON(workPlanTimerStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "session": session})
+}
+
+// 计时中的记录
+func (h *WorkPlanHandler) Timers(c *gin.Context) {
+	pp, _ := h.wp.ViewPlan(c.Param("hash"))
+	if pp == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrPlanNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"timers": pp.Timers()})
+}
+
+// 用时报表：?from=&to=（unix 秒 / RFC3339 / YYYY-MM-DD，默认全部）&member=&tz=Asia/Shanghai
+func (h *WorkPlanHandler) TimeReport(c *gin.Context) {
+	pp, _ := h.wp.ViewPlan(c.Param("hash"))
+	if pp == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrPlanNotFound.Error()})
+		return
+	}
+
+	from, err := parseTimeParam(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: " + err.Error()})
+		return
+	}
+	to, err := parseTimeParam(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: " + err.Error()})
+		return
+	}
+	loc := time.Local
+	if tz := c.Query("tz"); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tz: " + err.Error()})
+			return
+		}
+	}
+	member, _ := strconv.Atoi(c.Query("member"))
+
+	report, err := pp.TimeReport(from, to, member, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func workPlanTimerStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrTODONotFound), errors.Is(err, service.ErrMemberNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrTimerNotRunning), errors.Is(err, service.ErrTimerTooMany):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
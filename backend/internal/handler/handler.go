@@ -1,7 +1,12 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"DevDesk/internal/service"
 
@@ -19,7 +24,7 @@ func NewHandler() http.Handler {
 		if origin != "" {
 			c.Header("Access-Control-Allow-Origin", origin)
 			c.Header("Access-Control-Allow-Credentials", "true")
-			c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CodeShare-Admin")
 			c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 		}
 
@@ -33,16 +38,55 @@ func NewHandler() http.Handler {
 	// =======================================
 
 	// CodeShare 分组
-	svc := service.NewCodeShareService()
+	// 存储后端通过环境变量选择：CODESHARE_STORAGE=memory|bolt，CODESHARE_DATA_PATH=数据文件
+	// 有效期策略：CODESHARE_TTL_MIN / CODESHARE_TTL_MAX / CODESHARE_TTL_DEFAULT（秒或 10m、720h 这样的时长），
+	// CODESHARE_MAX_PINNED 为置顶数量上限（默认禁止置顶），CODESHARE_ADMIN_TOKEN 设置后置顶需在 X-CodeShare-Admin 头中携带
+	svc, err := service.NewCodeShareService(service.CodeShareConfig{
+		Storage:  os.Getenv("CODESHARE_STORAGE"),
+		DataPath: os.Getenv("CODESHARE_DATA_PATH"),
+		TTL: service.TTLPolicy{
+			Min:        envSeconds("CODESHARE_TTL_MIN"),
+			Max:        envSeconds("CODESHARE_TTL_MAX"),
+			Default:    envSeconds("CODESHARE_TTL_DEFAULT"),
+			MaxPinned:  envInt("CODESHARE_MAX_PINNED"),
+			AdminToken: os.Getenv("CODESHARE_ADMIN_TOKEN"),
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
 	codeHandler := NewCodeShareHandler(svc)
 	cg := r.Group("/codeshare")
 	{
+		cg.POST("", codeHandler.UploadRaw)
+		cg.GET("/raw/:hash", codeHandler.Raw)
 		cg.POST("/upload", codeHandler.Upload)
 		cg.GET("/code/:hash", codeHandler.Get)
+		cg.GET("/list", codeHandler.List)
+		cg.POST("/fork", codeHandler.Fork)
+		cg.POST("/revise", codeHandler.Revise)
+		cg.POST("/update", codeHandler.Update)
+		cg.POST("/extend", codeHandler.Extend)
+		cg.GET("/ttl", codeHandler.TTLOptions)
+		cg.POST("/delete", codeHandler.Delete)
+		cg.GET("/revisions/:hash", codeHandler.Revisions)
+		cg.GET("/diff/:hash", codeHandler.Diff)
+		cg.GET("/comments/:hash", codeHandler.Comments)
+		cg.GET("/comments/:hash/stream", codeHandler.StreamComments)
+		cg.POST("/comment", codeHandler.AddComment)
+		cg.POST("/comment/resolve", codeHandler.ResolveComment)
+		cg.GET("/render/:hash", codeHandler.Render)
+		cg.GET("/archive/:hash", codeHandler.Archive)
+		cg.POST("/gocheck", codeHandler.GoCheck)
+		cg.GET("/themes", codeHandler.Themes)
+		cg.GET("/stats", codeHandler.Stats)
 	}
 
 	// WorkPlan 分组
-	workPlanService := service.NewWorkPlan()
+	// WORKPLAN_MAX_TODOS 为单个计划的 TODO 上限（负数表示不限制）
+	workPlanService := service.NewWorkPlan(service.WorkPlanConfig{
+		MaxTODOs: envInt("WORKPLAN_MAX_TODOS"),
+	})
 	workPlanHandler := NewWorkPlanHandler(workPlanService)
 	wg := r.Group("/workplan")
 	{
@@ -51,6 +95,24 @@ func NewHandler() http.Handler {
 		wg.POST("/delete", workPlanHandler.DeleteTODO)
 		wg.POST("/edit", workPlanHandler.EditTODO)
 		wg.POST("/done", workPlanHandler.SetDone)
+		wg.POST("/move", workPlanHandler.MoveTODO)
+		wg.POST("/settings", workPlanHandler.UpdateSettings)
+		wg.GET("/stream/:hash", workPlanHandler.StreamPlan)
+		wg.GET("/ics/:hash", workPlanHandler.ExportICS)
+		wg.POST("/ics/:hash", workPlanHandler.ImportICS)
+		wg.GET("/history/:hash", workPlanHandler.History)
+		wg.POST("/member/add", workPlanHandler.AddMember)
+		wg.POST("/member/edit", workPlanHandler.RenameMember)
+		wg.POST("/member/delete", workPlanHandler.RemoveMember)
+		wg.GET("/activity/:hash", workPlanHandler.Activity)
+		wg.POST("/undo", workPlanHandler.Undo)
+		wg.POST("/redo", workPlanHandler.Redo)
+		wg.POST("/timer/start", workPlanHandler.StartTimer)
+		wg.POST("/timer/stop", workPlanHandler.StopTimer)
+		wg.GET("/timer/:hash", workPlanHandler.Timers)
+		wg.GET("/report/:hash", workPlanHandler.TimeReport)
+		wg.GET("/export/:hash", workPlanHandler.Export)
+		wg.POST("/import/:hash", workPlanHandler.Import)
 		wg.GET("/:hash", workPlanHandler.GetTODOs)
 	}
 
@@ -90,3 +152,29 @@ func NewHandler() http.Handler {
 
 	return r
 }
+
+// envSeconds 读取秒数或 time.Duration 格式的环境变量，未设置或无法解析时返回 0
+func envSeconds(name string) int64 {
+	v := os.Getenv(name)
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return int64(d / time.Second)
+	}
+	return 0
+}
+
+func envInt(name string) int {
+	n, _ := strconv.Atoi(os.Getenv(name))
+	return n
+}
+
+// baseURL 返回当前请求的 scheme://host，考虑反向代理的 X-Forwarded-Proto
+func baseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, c.Request.Host)
+}
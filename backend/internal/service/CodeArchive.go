@@ -0,0 +1,74 @@
+// CodeShare 打包下载：zip / tar.gz
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+var ErrArchiveFormat = errors.New("format must be zip or tar.gz")
+
+// WriteArchive 将片段的所有文件写入压缩包，文件位于以 hash 命名的目录下
+func WriteArchive(w io.Writer, code *Code, format string) error {
+	if code.Encrypted() {
+		return ErrCodeEncrypted
+	}
+
+	files := code.AllFiles()
+	modTime := time.Now()
+	if n := len(code.Revisions); n > 0 {
+		modTime = time.Unix(code.Revisions[n-1].CreatedAt, 0)
+	}
+
+	switch format {
+	case ArchiveZip:
+		zw := zip.NewWriter(w)
+		for _, f := range files {
+			fw, err := zw.CreateHeader(&zip.FileHeader{
+				Name:     code.Hash + "/" + f.Name,
+				Method:   zip.Deflate,
+				Modified: modTime,
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(fw, f.Content); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+
+	case ArchiveTarGz:
+		gw := gzip.NewWriter(w)
+		tw := tar.NewWriter(gw)
+		for _, f := range files {
+			err := tw.WriteHeader(&tar.Header{
+				Name:    code.Hash + "/" + f.Name,
+				Mode:    0o644,
+				Size:    int64(len(f.Content)),
+				ModTime: modTime,
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(tw, f.Content); err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return gw.Close()
+	}
+
+	return ErrArchiveFormat
+}
@@ -0,0 +1,106 @@
+// CodeShare 正文去重：正文按 SHA-256 摘要存储，元数据只保存摘要
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrCodeBodyMissing = errors.New("code body missing")
+
+// codeBody 为一个版本的正文（单文件内容或文件列表）
+type codeBody struct {
+	Content string
+	Files   []CodeFile
+}
+
+func (b codeBody) size() int64 {
+	return contentSize(b.Content, b.Files)
+}
+
+func contentSize(content string, files []CodeFile) int64 {
+	n := int64(len(content))
+	for _, f := range files {
+		n += int64(len(f.Content))
+	}
+	return n
+}
+
+// bodyDigest 计算正文摘要；单文件时即内容本身的 sha256
+func bodyDigest(content string, files []CodeFile) string {
+	h := sha256.New()
+	if len(files) == 0 {
+		h.Write([]byte(content))
+	} else {
+		for _, f := range files {
+			fmt.Fprintf(h, "%s\x00%s\x00%d\x00", f.Name, f.Language, len(f.Content))
+			h.Write([]byte(f.Content))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// split 返回去掉正文、只保留摘要的元数据副本，以及按摘要索引的正文；
+// 未读取正文的历史版本（只有摘要）不在返回的正文中，由存储沿用已有的正文
+func (c *Code) split() (*Code, map[string]codeBody) {
+	bodies := make(map[string]codeBody)
+
+	meta := *c
+	meta.Digest = bodyDigest(c.Content, c.Files)
+	bodies[meta.Digest] = codeBody{Content: c.Content, Files: c.Files}
+	meta.Content, meta.Files = "", nil
+	meta.Comments = slices.Clone(c.Comments)
+
+	// 历史版本不可变，已有摘要时直接沿用
+	meta.Revisions = make([]CodeRevision, len(c.Revisions))
+	for i, r := range c.Revisions {
+		switch {
+		case r.Digest == "":
+			r.Digest = bodyDigest(r.Content, r.Files)
+			bodies[r.Digest] = codeBody{Content: r.Content, Files: r.Files}
+		case r.Content != "" || r.Files != nil:
+			bodies[r.Digest] = codeBody{Content: r.Content, Files: r.Files}
+		}
+		r.Content, r.Files = "", nil
+		meta.Revisions[i] = r
+	}
+	return &meta, bodies
+}
+
+// digests 返回元数据引用的摘要，每个引用位置各计一次
+func (c *Code) digests() []string {
+	ds := make([]string, 0, len(c.Revisions)+1)
+	ds = append(ds, c.Digest)
+	for _, r := range c.Revisions {
+		ds = append(ds, r.Digest)
+	}
+	return ds
+}
+
+// join 按摘要填回当前版本的正文，history 为 true 时同时填回全部历史版本，否则历史版本只有摘要；
+// 返回完整的副本，切片均为新分配，调用方可以就地修改
+func (c *Code) join(body func(digest string) (codeBody, bool), history bool) (*Code, error) {
+	code := *c
+	b, ok := body(c.Digest)
+	if !ok {
+		return nil, ErrCodeBodyMissing
+	}
+	code.Content, code.Files = b.Content, slices.Clone(b.Files)
+	code.Comments = slices.Clone(c.Comments)
+	code.Revisions = slices.Clone(c.Revisions)
+	if !history {
+		return &code, nil
+	}
+
+	for i, r := range code.Revisions {
+		b, ok := body(r.Digest)
+		if !ok {
+			return nil, ErrCodeBodyMissing
+		}
+		code.Revisions[i].Content, code.Revisions[i].Files = b.Content, slices.Clone(b.Files)
+	}
+	return &code, nil
+}
@@ -0,0 +1,292 @@
+// CodeShare 行评论：锚定到某个版本的行区间，支持回复、解决，并实时推送给正在查看的客户端
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MaxComments    = 500
+	MaxCommentSize = 4000
+
+	CommentEventAdded    = "comment"
+	CommentEventResolved = "resolved"
+)
+
+var (
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrCommentEmpty    = errors.New("comment body is empty")
+	ErrCommentTooLarge = fmt.Errorf("comment must be at most %d bytes", MaxCommentSize)
+	ErrCommentTooMany  = fmt.Errorf("at most %d comments per code", MaxComments)
+	ErrCommentRange    = errors.New("invalid line range")
+)
+
+// CodeComment 为一条评论；ParentID 为 0 时是一个讨论串的起点，回复沿用起点的锚点
+type CodeComment struct {
+	ID       int `json:"id"`
+	ParentID int `json:"parent_id,omitempty"`
+	Revision int `json:"revision"`
+	// File 为多文件片段中的文件名，单文件片段为空
+	File      string `json:"file,omitempty"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Author    string `json:"author"`
+	Body      string `json:"body"`
+	// Resolved 只记录在讨论串起点上
+	Resolved  bool  `json:"resolved"`
+	CreatedAt int64 `json:"created_at"`
+}
+
+// CommentThread 为一个讨论串及其回复（按时间顺序）
+type CommentThread struct {
+	CodeComment
+	Replies []CodeComment `json:"replies"`
+}
+
+// CommentInput 为新增评论的参数；ParentID 不为 0 时为回复，锚点参数被忽略
+type CommentInput struct {
+	Author    string
+	Body      string
+	File      string
+	StartLine int
+	EndLine   int
+	ParentID  int
+}
+
+// CommentEvent 为推送给订阅者的事件
+type CommentEvent struct {
+	Type    string      `json:"type"`
+	Comment CodeComment `json:"comment"`
+}
+
+// Comments 按讨论串返回评论，不计入浏览；与 Peek 一样，有浏览上限的片段不提供评论
+func (cs *CodeShare) Comments(hash string) ([]CommentThread, error) {
+	code, err := cs.Peek(hash)
+	if err != nil {
+		return nil, err
+	}
+	return code.threads(), nil
+}
+
+// AddComment 新增评论或回复，并推送给订阅者
+func (cs *CodeShare) AddComment(hash string, in *CommentInput) (*CodeComment, error) {
+	in.Body = strings.TrimSpace(in.Body)
+	if in.Body == "" {
+		return nil, ErrCommentEmpty
+	}
+	if len(in.Body) > MaxCommentSize {
+		return nil, ErrCommentTooLarge
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	code, err := cs.Peek(hash)
+	if err != nil {
+		return nil, err
+	}
+	if code.Encrypted() {
+		return nil, ErrCodeEncrypted
+	}
+	if len(code.Comments) >= MaxComments {
+		return nil, ErrCommentTooMany
+	}
+
+	cm := CodeComment{
+		ID:        len(code.Comments) + 1,
+		Author:    in.Author,
+		Body:      in.Body,
+		CreatedAt: time.Now().Unix(),
+	}
+	if in.ParentID != 0 {
+		root := code.comment(in.ParentID)
+		if root == nil {
+			return nil, ErrCommentNotFound
+		}
+		// 回复挂在讨论串起点下，不形成更深的嵌套
+		if root.ParentID != 0 {
+			root = code.comment(root.ParentID)
+		}
+		cm.ParentID = root.ID
+		cm.Revision, cm.File = root.Revision, root.File
+		cm.StartLine, cm.EndLine = root.StartLine, root.EndLine
+	} else {
+		if err := code.checkAnchor(in.File, in.StartLine, in.EndLine); err != nil {
+			return nil, err
+		}
+		cm.Revision, cm.File = code.Revision, in.File
+		cm.StartLine, cm.EndLine = in.StartLine, in.EndLine
+		if cm.EndLine == 0 {
+			cm.EndLine = cm.StartLine
+		}
+	}
+
+	code.Comments = append(code.Comments, cm)
+	if err := cs.store.Put(code); err != nil {
+		return nil, err
+	}
+
+	cs.comments.publish(hash, CommentEvent{Type: CommentEventAdded, Comment: cm})
+	return &cm, nil
+}
+
+// ResolveComment 将讨论串标记为已解决或重新打开；id 为回复时作用于其所在讨论串
+func (cs *CodeShare) ResolveComment(hash string, id int, resolved bool) (*CodeComment, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	code, err := cs.Peek(hash)
+	if err != nil {
+		return nil, err
+	}
+	root := code.comment(id)
+	if root == nil {
+		return nil, ErrCommentNotFound
+	}
+	if root.ParentID != 0 {
+		root = code.comment(root.ParentID)
+	}
+
+	root.Resolved = resolved
+	if err := cs.store.Put(code); err != nil {
+		return nil, err
+	}
+
+	cm := *root
+	cs.comments.publish(hash, CommentEvent{Type: CommentEventResolved, Comment: cm})
+	return &cm, nil
+}
+
+// SubscribeComments 订阅片段的评论事件，返回的通道需通过 UnsubscribeComments 释放
+func (cs *CodeShare) SubscribeComments(hash string) (chan CommentEvent, error) {
+	if _, err := cs.Peek(hash); err != nil {
+		return nil, err
+	}
+	return cs.comments.subscribe(hash), nil
+}
+
+func (cs *CodeShare) UnsubscribeComments(hash string, ch chan CommentEvent) {
+	cs.comments.unsubscribe(hash, ch)
+}
+
+// comment 按 ID 查找评论，返回可修改的指针
+func (c *Code) comment(id int) *CodeComment {
+	if id <= 0 || id > len(c.Comments) {
+		return nil
+	}
+	return &c.Comments[id-1]
+}
+
+// checkAnchor 校验评论锚点位于当前版本对应文件的行范围内；
+// 错误中不带行数与文件信息，避免借此试探内容
+func (c *Code) checkAnchor(file string, start, end int) error {
+	content := c.Content
+	if c.IsMultiFile() {
+		found := false
+		for _, f := range c.Files {
+			if f.Name == file {
+				content, found = f.Content, true
+				break
+			}
+		}
+		if !found {
+			return ErrCommentRange
+		}
+	} else if file != "" {
+		return ErrCommentRange
+	}
+
+	if end == 0 {
+		end = start
+	}
+	lines := strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+	if start < 1 || end < start || end > lines {
+		return ErrCommentRange
+	}
+	return nil
+}
+
+// commentBytes 为评论占用的字节数，计入存储的字节上限
+func (c *Code) commentBytes() int64 {
+	var n int64
+	for _, cm := range c.Comments {
+		n += int64(len(cm.Author) + len(cm.Body) + len(cm.File))
+	}
+	return n
+}
+
+func (c *Code) threads() []CommentThread {
+	threads := []CommentThread{}
+	index := make(map[int]int)
+	for _, cm := range c.Comments {
+		if cm.ParentID == 0 {
+			index[cm.ID] = len(threads)
+			threads = append(threads, CommentThread{CodeComment: cm, Replies: []CodeComment{}})
+			continue
+		}
+		if i, ok := index[cm.ParentID]; ok {
+			threads[i].Replies = append(threads[i].Replies, cm)
+		}
+	}
+	return threads
+}
+
+// commentHub 按片段 hash 管理评论订阅者
+type commentHub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan CommentEvent]struct{}
+}
+
+func newCommentHub() *commentHub {
+	return &commentHub{subs: make(map[string]map[chan CommentEvent]struct{})}
+}
+
+func (h *commentHub) subscribe(hash string) chan CommentEvent {
+	ch := make(chan CommentEvent, 10)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subs[hash] == nil {
+		h.subs[hash] = make(map[chan CommentEvent]struct{})
+	}
+	h.subs[hash][ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe 注销订阅者，并关闭通道
+func (h *commentHub) unsubscribe(hash string, ch chan CommentEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[hash][ch]; ok {
+		delete(h.subs[hash], ch)
+		close(ch)
+	}
+	if len(h.subs[hash]) == 0 {
+		delete(h.subs, hash)
+	}
+}
+
+// publish 向订阅者推送事件；缓冲区已满的订阅者被注销并关闭通道，
+// 客户端重连后重新获取评论列表，而不是静默错过事件
+func (h *commentHub) publish(hash string, ev CommentEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs[hash] {
+		select {
+		case ch <- ev:
+		default:
+			delete(h.subs[hash], ch)
+			close(ch)
+		}
+	}
+	if len(h.subs[hash]) == 0 {
+		delete(h.subs, hash)
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommentAccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxViews int
+		in       CommentInput
+		wantErr  error
+	}{
+		{name: "ok", in: CommentInput{Author: "a", Body: "nice", StartLine: 2}},
+		{name: "view limited", maxViews: 3, in: CommentInput{Author: "a", Body: "nice", StartLine: 1}, wantErr: ErrCodeViewLimited},
+		{name: "line out of range", in: CommentInput{Author: "a", Body: "nice", StartLine: 4}, wantErr: ErrCommentRange},
+		{name: "unknown file", in: CommentInput{Author: "a", Body: "nice", File: "x.go", StartLine: 1}, wantErr: ErrCommentRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, err := NewCodeShareService(CodeShareConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := cs.Upload(&CodeUpload{Author: "a", Language: "go", Content: "package main\n\nfunc main() {}", MaxViews: tt.maxViews})
+			if err != nil {
+				t.Fatal(err)
+			}
+			hash := res.Code.Hash
+
+			_, err = cs.AddComment(hash, &tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("add err = %v, want %v", err, tt.wantErr)
+			}
+			// 错误信息不能透露行数
+			if errors.Is(err, ErrCommentRange) && err.Error() != ErrCommentRange.Error() {
+				t.Errorf("range error leaks details: %q", err)
+			}
+			if tt.maxViews > 0 {
+				if _, err := cs.Comments(hash); !errors.Is(err, ErrCodeViewLimited) {
+					t.Errorf("comments err = %v, want %v", err, ErrCodeViewLimited)
+				}
+				if _, err := cs.SubscribeComments(hash); !errors.Is(err, ErrCodeViewLimited) {
+					t.Errorf("subscribe err = %v, want %v", err, ErrCodeViewLimited)
+				}
+				// 评论接口不计入浏览
+				if code, ok, _ := cs.store.Get(hash); !ok || code.Views != 0 {
+					t.Errorf("views consumed by comment endpoints")
+				}
+			}
+		})
+	}
+}
+
+func TestCommentSlowSubscriber(t *testing.T) {
+	cs, err := NewCodeShareService(CodeShareConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := cs.Upload(&CodeUpload{Author: "a", Language: "go", Content: "package main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := res.Code.Hash
+
+	slow, err := cs.SubscribeComments(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < cap(slow)+1; i++ {
+		if _, err := cs.AddComment(hash, &CommentInput{Author: "a", Body: "hi", StartLine: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 缓冲区满后通道被关闭，已缓冲的事件仍可读出
+	got := 0
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-slow:
+			if !ok {
+				if got != cap(slow) {
+					t.Errorf("received %d events before close, want %d", got, cap(slow))
+				}
+				// 已被注销，再次注销不会重复关闭
+				cs.UnsubscribeComments(hash, slow)
+				return
+			}
+			got++
+		case <-timeout:
+			t.Fatal("slow subscriber was not disconnected")
+		}
+	}
+}
+
+func TestCommentBytesCharged(t *testing.T) {
+	future := time.Now().Unix() + 3600
+	body := strings.Repeat("x", 100)
+
+	for name, s := range testStores(t, 0, 0) {
+		t.Run(name, func(t *testing.T) {
+			code := testCode("a", "code", future)
+			mustPut(t, s, code)
+			code.Comments = []CodeComment{{ID: 1, Author: "bob", Body: body}}
+			mustPut(t, s, code)
+			if got, want := s.Stats().Bytes, int64(len("code")+len("bob")+len(body)); got != want {
+				t.Fatalf("bytes = %d, want %d", got, want)
+			}
+			if err := s.Delete("a"); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Stats().Bytes; got != 0 {
+				t.Fatalf("bytes after delete = %d, want 0", got)
+			}
+		})
+	}
+
+	// 评论占用的字节数超出上限时淘汰其他片段
+	for name, s := range testStores(t, 0, 150) {
+		t.Run("evicts/"+name, func(t *testing.T) {
+			mustPut(t, s, testCode("old", "old", future))
+			code := testCode("new", "new", future)
+			code.Comments = []CodeComment{{ID: 1, Author: "bob", Body: body}, {ID: 2, Author: "bob", Body: body}}
+			mustPut(t, s, code)
+			if _, ok, _ := s.Get("old"); ok {
+				t.Error("comment bytes did not count toward the byte limit")
+			}
+		})
+	}
+}
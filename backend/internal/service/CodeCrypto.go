@@ -0,0 +1,51 @@
+// CodeShare 零知识加密：客户端加密，服务端只保存密文和算法参数
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrCodeEncrypted     = errors.New("code is encrypted")
+	ErrCodeCipherInvalid = errors.New("invalid encrypted content")
+)
+
+// 支持的算法及其 nonce 长度（字节）
+var codeCipherNonceSizes = map[string]int{
+	"AES-GCM": 12,
+}
+
+// AEAD 认证标签长度
+const codeCipherTagSize = 16
+
+// CodeEncryption 描述客户端使用的加密参数，密钥只存在于分享链接的 # 片段中
+type CodeEncryption struct {
+	Algorithm string `json:"algorithm"`
+	// Nonce 为 base64 编码
+	Nonce string `json:"nonce"`
+}
+
+// validate 校验密文格式，返回对应的明文长度
+func (e *CodeEncryption) validate(ciphertext string) (int, error) {
+	nonceSize, ok := codeCipherNonceSizes[e.Algorithm]
+	if !ok {
+		return 0, fmt.Errorf("%w: unsupported algorithm %q", ErrCodeCipherInvalid, e.Algorithm)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(e.Nonce)
+	if err != nil || len(nonce) != nonceSize {
+		return 0, fmt.Errorf("%w: nonce must be %d bytes base64", ErrCodeCipherInvalid, nonceSize)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return 0, fmt.Errorf("%w: content must be base64", ErrCodeCipherInvalid)
+	}
+	if len(data) < codeCipherTagSize {
+		return 0, fmt.Errorf("%w: ciphertext too short", ErrCodeCipherInvalid)
+	}
+
+	return len(data) - codeCipherTagSize, nil
+}
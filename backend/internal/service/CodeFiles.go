@@ -0,0 +1,98 @@
+// CodeShare 多文件片段：一个 hash 下包含多个带名字和语言的文件
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	MaxCodeFiles        = 20
+	MaxCodeFileNameSize = 128
+)
+
+var (
+	ErrCodeEmpty          = errors.New("content or files is required")
+	ErrCodeFileName       = errors.New("invalid file name")
+	ErrCodeTooManyFiles   = fmt.Errorf("at most %d files per code", MaxCodeFiles)
+	ErrCodeEncryptedFiles = errors.New("encrypted code must be a single file")
+)
+
+type CodeFile struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// 常见语言的扩展名，用于单文件片段打包下载时命名
+var codeFileExts = map[string]string{
+	"go":         ".go",
+	"javascript": ".js",
+	"typescript": ".ts",
+	"vue":        ".vue",
+	"python":     ".py",
+	"c_cpp":      ".cpp",
+	"c":          ".c",
+	"cpp":        ".cpp",
+	"java":       ".java",
+	"rust":       ".rs",
+	"shell":      ".sh",
+	"bash":       ".sh",
+	"sql":        ".sql",
+	"json":       ".json",
+	"yaml":       ".yaml",
+	"html":       ".html",
+	"css":        ".css",
+	"markdown":   ".md",
+}
+
+// IsMultiFile 表示片段由 Files 组成，而不是单个 Content
+func (c *Code) IsMultiFile() bool {
+	return len(c.Files) > 0
+}
+
+// AllFiles 统一返回文件列表；单文件片段会被包装为一个文件
+func (c *Code) AllFiles() []CodeFile {
+	if c.IsMultiFile() {
+		return c.Files
+	}
+	return []CodeFile{{
+		Name:     defaultFileName(c.Hash, c.Language),
+		Language: c.Language,
+		Content:  c.Content,
+	}}
+}
+
+func defaultFileName(hash, lang string) string {
+	ext, ok := codeFileExts[strings.ToLower(lang)]
+	if !ok {
+		ext = ".txt"
+	}
+	return hash + ext
+}
+
+// validateFiles 校验文件名并返回所有文件内容的总字节数
+func validateFiles(files []CodeFile) (int, error) {
+	if len(files) > MaxCodeFiles {
+		return 0, ErrCodeTooManyFiles
+	}
+
+	seen := make(map[string]struct{}, len(files))
+	size := 0
+	for _, f := range files {
+		name := f.Name
+		if name == "" || len(name) > MaxCodeFileNameSize ||
+			strings.ContainsAny(name, `/\`) || name == "." || name == ".." ||
+			path.Clean(name) != name {
+			return 0, fmt.Errorf("%w: %q", ErrCodeFileName, name)
+		}
+		if _, dup := seen[name]; dup {
+			return 0, fmt.Errorf("%w: duplicate %q", ErrCodeFileName, name)
+		}
+		seen[name] = struct{}{}
+		size += len(f.Content)
+	}
+	return size, nil
+}
@@ -0,0 +1,128 @@
+// O(1) LRU 索引：map + 双向链表，按去重后的正文数量和字节数淘汰
+package service
+
+import (
+	"container/list"
+)
+
+// 正文去重后元数据可能远多于正文，元数据条数上限为 maxEntries 的倍数
+const codeRecordFactor = 16
+
+// lruIndex 只记录 key 的使用顺序与正文用量，数据本身由各存储实现保存；非并发安全，调用方需加锁
+type lruIndex struct {
+	ll    *list.List // 头部为最近使用，元素为 key
+	items map[string]*list.Element
+	// pinned 中的 key 永不过期，也不会被淘汰
+	pinned map[string]struct{}
+	// bytes / blobs 为去重后正文的字节数与数量，由存储实现通过 charge 维护；
+	// bytes 还包括各记录自身计入的字节数（如评论），记录在 sizes 中
+	bytes int64
+	blobs int
+	sizes map[string]int64
+
+	maxEntries int
+	maxBytes   int64
+	evictions  uint64
+}
+
+func newLRUIndex(maxEntries int, maxBytes int64) *lruIndex {
+	return &lruIndex{
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		pinned:     make(map[string]struct{}),
+		sizes:      make(map[string]int64),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+	}
+}
+
+// add 插入 key 或将其标记为最近使用，并更新置顶状态与记录自身的字节数
+func (l *lruIndex) add(key string, pinned bool, size int64) {
+	if pinned {
+		l.pinned[key] = struct{}{}
+	} else {
+		delete(l.pinned, key)
+	}
+	l.bytes += size - l.sizes[key]
+	if size > 0 {
+		l.sizes[key] = size
+	} else {
+		delete(l.sizes, key)
+	}
+	if e, ok := l.items[key]; ok {
+		l.ll.MoveToFront(e)
+		return
+	}
+	l.items[key] = l.ll.PushFront(key)
+}
+
+// charge 记录正文用量的变化
+func (l *lruIndex) charge(bytes int64, blobs int) {
+	l.bytes += bytes
+	l.blobs += blobs
+}
+
+// evict 超出容量时从最久未使用的一端开始淘汰，protect 与置顶的 key 不会被淘汰；
+// drop 负责删除数据并通过 charge 释放用量，出错时停止淘汰
+func (l *lruIndex) evict(protect string, drop func(key string) error) error {
+	for e := l.ll.Back(); e != nil && l.overflow(); {
+		prev := e.Prev()
+		if key := e.Value.(string); key != protect && !l.isPinned(key) {
+			if err := drop(key); err != nil {
+				return err
+			}
+			l.remove(key)
+			l.evictions++
+		}
+		e = prev
+	}
+	return nil
+}
+
+// touch 将 key 标记为最近使用，不存在时返回 false
+func (l *lruIndex) touch(key string) bool {
+	e, ok := l.items[key]
+	if ok {
+		l.ll.MoveToFront(e)
+	}
+	return ok
+}
+
+func (l *lruIndex) remove(key string) bool {
+	e, ok := l.items[key]
+	if !ok {
+		return false
+	}
+	l.ll.Remove(e)
+	delete(l.items, key)
+	delete(l.pinned, key)
+	l.bytes -= l.sizes[key]
+	delete(l.sizes, key)
+	return true
+}
+
+func (l *lruIndex) isPinned(key string) bool {
+	_, ok := l.pinned[key]
+	return ok
+}
+
+func (l *lruIndex) len() int {
+	return l.ll.Len()
+}
+
+func (l *lruIndex) overflow() bool {
+	if l.maxEntries > 0 && (l.blobs > l.maxEntries || l.ll.Len() > l.maxEntries*codeRecordFactor) {
+		return true
+	}
+	return l.maxBytes > 0 && l.bytes > l.maxBytes
+}
+
+func (l *lruIndex) stats() CodeStoreStats {
+	return CodeStoreStats{
+		Entries:   l.ll.Len(),
+		Blobs:     l.blobs,
+		Pinned:    len(l.pinned),
+		Bytes:     l.bytes,
+		Evictions: l.evictions,
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLRUIndexEvict(t *testing.T) {
+	type op struct {
+		key    string
+		size   int64
+		pinned bool
+		touch  bool
+	}
+	tests := []struct {
+		name       string
+		maxEntries int
+		maxBytes   int64
+		ops        []op
+		evicted    []string
+		order      []string // 从最近使用到最久未使用
+	}{
+		{
+			name:       "oldest first",
+			maxEntries: 2,
+			ops:        []op{{key: "a", size: 1}, {key: "b", size: 1}, {key: "c", size: 1}},
+			evicted:    []string{"a"},
+			order:      []string{"c", "b"},
+		},
+		{
+			name:       "touch refreshes",
+			maxEntries: 2,
+			ops:        []op{{key: "a", size: 1}, {key: "b", size: 1}, {key: "a", touch: true}, {key: "c", size: 1}},
+			evicted:    []string{"b"},
+			order:      []string{"c", "a"},
+		},
+		{
+			name:       "pinned never evicted",
+			maxEntries: 2,
+			ops:        []op{{key: "p", size: 1, pinned: true}, {key: "a", size: 1}, {key: "b", size: 1}, {key: "c", size: 1}},
+			evicted:    []string{"a", "b"},
+			order:      []string{"c", "p"},
+		},
+		{
+			name:     "byte limit",
+			maxBytes: 10,
+			ops:      []op{{key: "a", size: 4}, {key: "b", size: 4}, {key: "c", size: 4}},
+			evicted:  []string{"a"},
+			order:    []string{"c", "b"},
+		},
+		{
+			name:     "new key is protected",
+			maxBytes: 10,
+			ops:      []op{{key: "a", size: 4}, {key: "b", size: 20}},
+			evicted:  []string{"a"},
+			order:    []string{"b"},
+		},
+		{
+			name:       "unpinning makes key evictable",
+			maxEntries: 2,
+			ops:        []op{{key: "a", size: 1, pinned: true}, {key: "b", size: 1}, {key: "a"}, {key: "b", touch: true}, {key: "c", size: 1}},
+			evicted:    []string{"a"},
+			order:      []string{"c", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLRUIndex(tt.maxEntries, tt.maxBytes)
+			sizes := make(map[string]int64)
+			var evicted []string
+			for _, o := range tt.ops {
+				if o.touch {
+					if !l.touch(o.key) {
+						t.Fatalf("touch %q: not found", o.key)
+					}
+					continue
+				}
+				if _, ok := sizes[o.key]; !ok && o.size > 0 {
+					sizes[o.key] = o.size
+					l.charge(o.size, 1)
+				}
+				l.add(o.key, o.pinned, 0)
+				err := l.evict(o.key, func(key string) error {
+					l.charge(-sizes[key], -1)
+					delete(sizes, key)
+					evicted = append(evicted, key)
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !slices.Equal(evicted, tt.evicted) {
+				t.Errorf("evicted = %v, want %v", evicted, tt.evicted)
+			}
+			var order []string
+			for e := l.ll.Front(); e != nil; e = e.Next() {
+				order = append(order, e.Value.(string))
+			}
+			if !slices.Equal(order, tt.order) {
+				t.Errorf("order = %v, want %v", order, tt.order)
+			}
+			if st := l.stats(); st.Evictions != uint64(len(tt.evicted)) || st.Entries != len(tt.order) {
+				t.Errorf("stats = %+v", st)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// CodeShare 公开列表：按语言 / 作者 / 时间过滤，支持内容检索与分页
+package service
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultListSize = 20
+	MaxListSize     = 100
+
+	// 预览最多取前几行 / 前若干字节
+	previewLines = 5
+	previewBytes = 300
+)
+
+// CodeQuery 为列表查询参数，零值表示不过滤
+type CodeQuery struct {
+	Language string
+	Author   string
+	// Since / Until 按创建时间（unix 秒）过滤，闭区间
+	Since int64
+	Until int64
+	// Query 为检索词，空白分隔的多个词需全部命中，双引号内为短语；不区分大小写
+	Query string
+	Page  int
+	Size  int
+}
+
+// CodeSummary 为列表中的一项，不包含完整内容
+type CodeSummary struct {
+	Hash     string   `json:"hash"`
+	Author   string   `json:"author"`
+	Language string   `json:"language"`
+	Files    []string `json:"files,omitempty"`
+	Size     int64    `json:"size"`
+	// 加密或限制浏览次数的片段不提供预览
+	Preview     string `json:"preview,omitempty"`
+	Encrypted   bool   `json:"encrypted,omitempty"`
+	Revision    int    `json:"revision"`
+	Views       int    `json:"views"`
+	CreatedAt   int64  `json:"created_at"`
+	DestroyTime int64  `json:"destroy_time"`
+}
+
+type CodeList struct {
+	Items []CodeSummary `json:"items"`
+	Total int           `json:"total"`
+	Page  int           `json:"page"`
+	Size  int           `json:"size"`
+}
+
+// List 返回公开且未过期的片段，按创建时间倒序；不计入浏览
+func (cs *CodeShare) List(q CodeQuery) (*CodeList, error) {
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.Size <= 0 {
+		q.Size = DefaultListSize
+	}
+	if q.Size > MaxListSize {
+		q.Size = MaxListSize
+	}
+
+	now := time.Now().Unix()
+	codes, err := cs.store.List(func(meta *Code) bool {
+		switch {
+		case !meta.Public || meta.Expired(now):
+			return false
+		case q.Language != "" && !strings.EqualFold(meta.Language, q.Language):
+			return false
+		case q.Author != "" && !strings.EqualFold(meta.Author, q.Author):
+			return false
+		case q.Since > 0 && meta.CreatedAt < q.Since:
+			return false
+		case q.Until > 0 && meta.CreatedAt > q.Until:
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	terms := searchTerms(q.Query)
+	matched := codes[:0]
+	for _, code := range codes {
+		if code.matches(terms) {
+			matched = append(matched, code)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if a.CreatedAt != b.CreatedAt {
+			return a.CreatedAt > b.CreatedAt
+		}
+		return a.Hash < b.Hash
+	})
+
+	list := &CodeList{Items: []CodeSummary{}, Total: len(matched), Page: q.Page, Size: q.Size}
+	start := (q.Page - 1) * q.Size
+	if start >= len(matched) {
+		return list, nil
+	}
+	end := min(start+q.Size, len(matched))
+	for _, code := range matched[start:end] {
+		list.Items = append(list.Items, code.summary())
+	}
+	return list, nil
+}
+
+// matches 判断所有检索词是否都出现在作者、文件名或内容中；
+// 加密内容与有浏览上限的内容不参与检索，检索不计浏览，否则可以借此试探内容
+func (c *Code) matches(terms []string) bool {
+	if len(terms) == 0 {
+		return true
+	}
+
+	fields := []string{strings.ToLower(c.Author)}
+	for _, f := range c.AllFiles() {
+		if c.IsMultiFile() {
+			fields = append(fields, strings.ToLower(f.Name))
+		}
+		if !c.Encrypted() && c.MaxViews == 0 {
+			fields = append(fields, strings.ToLower(f.Content))
+		}
+	}
+
+	for _, term := range terms {
+		found := false
+		for _, field := range fields {
+			if strings.Contains(field, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Code) summary() CodeSummary {
+	s := CodeSummary{
+		Hash:        c.Hash,
+		Author:      c.Author,
+		Language:    c.Language,
+		Size:        contentSize(c.Content, c.Files),
+		Encrypted:   c.Encrypted(),
+		Revision:    c.Revision,
+		Views:       c.Views,
+		CreatedAt:   c.CreatedAt,
+		DestroyTime: c.DestroyTime,
+	}
+	for _, f := range c.Files {
+		s.Files = append(s.Files, f.Name)
+	}
+	if !c.Encrypted() && c.MaxViews == 0 {
+		s.Preview = preview(c.AllFiles()[0].Content)
+	}
+	return s
+}
+
+func preview(content string) string {
+	lines := strings.SplitN(content, "\n", previewLines+1)
+	if len(lines) > previewLines {
+		lines = lines[:previewLines]
+	}
+	p := strings.Join(lines, "\n")
+	if len(p) > previewBytes {
+		p = strings.ToValidUTF8(p[:previewBytes], "")
+	}
+	return p
+}
+
+// searchTerms 拆分检索词，双引号内的内容作为一个短语
+func searchTerms(query string) []string {
+	var terms []string
+	for i, part := range strings.Split(query, `"`) {
+		if i%2 == 1 {
+			if part = strings.TrimSpace(part); part != "" {
+				terms = append(terms, strings.ToLower(part))
+			}
+			continue
+		}
+		for _, w := range strings.Fields(part) {
+			terms = append(terms, strings.ToLower(w))
+		}
+	}
+	return terms
+}
@@ -0,0 +1,97 @@
+// CodeShare 作者管理：凭上传时返回的 token 删除片段、修改内容或有效期
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var ErrCodeInvalidTTL = errors.New("ttl must be positive")
+
+// CodeUpdate 为修改参数，未设置的字段保持不变；修改内容会发布新版本
+type CodeUpdate struct {
+	Revise *CodeRevise
+	// TTL 从当前时间起重新计算，TTLNever 表示置顶，对已置顶的片段设置普通 TTL 即取消置顶
+	TTL    *int64
+	Public *bool
+	// AdminToken 为置顶所需的 admin token
+	AdminToken string
+}
+
+// owned 读取片段并校验管理 token，调用方需持有 cs.mu
+func (cs *CodeShare) owned(hash, token string) (*Code, error) {
+	code, ok := cs.load(hash)
+	if !ok {
+		return nil, ErrCodeNotFound
+	}
+	if !code.checkToken(token) {
+		return nil, ErrCodeForbidden
+	}
+	return code, nil
+}
+
+// Delete 由作者提前删除片段
+func (cs *CodeShare) Delete(hash, token string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if _, err := cs.owned(hash, token); err != nil {
+		return err
+	}
+	return cs.store.Delete(hash)
+}
+
+// Update 由作者修改内容、有效期（ttl 从当前时间起算）或公开状态
+func (cs *CodeShare) Update(hash, token string, u *CodeUpdate) (*Code, error) {
+	if u.Revise != nil {
+		if err := validateContent(u.Revise.Content, u.Revise.Files, u.Revise.Encryption); err != nil {
+			return nil, err
+		}
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	code, err := cs.owned(hash, token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	if u.Revise != nil {
+		if err := code.applyRevision(u.Revise, now); err != nil {
+			return nil, err
+		}
+	}
+	if u.TTL != nil && !(*u.TTL == TTLNever && code.Pinned()) {
+		destroy, err := cs.destroyTime(*u.TTL, now, u.AdminToken)
+		if err != nil {
+			return nil, err
+		}
+		code.DestroyTime = destroy
+	}
+	if u.Public != nil {
+		code.Public = *u.Public
+	}
+
+	if err := cs.store.Put(code); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// checkToken 以常量时间比较 token 摘要
+func (c *Code) checkToken(token string) bool {
+	if c.TokenHash == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.TokenHash), []byte(hashToken(token))) == 1
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
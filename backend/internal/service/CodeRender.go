@@ -0,0 +1,114 @@
+// CodeShare 服务端渲染：按 Language 高亮，输出独立 HTML 页面
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+const DefaultRenderTheme = "github"
+
+type RenderOptions struct {
+	Theme       string
+	LineNumbers bool
+}
+
+// RenderThemes 返回可选的高亮主题
+func RenderThemes() []string {
+	return styles.Names()
+}
+
+// RenderCodeHTML 将代码渲染为不依赖前端的完整 HTML 页面
+func RenderCodeHTML(code *Code, opt RenderOptions) (string, error) {
+	if code.Encrypted() {
+		return "", ErrCodeEncrypted
+	}
+
+	style, ok := styles.Registry[strings.ToLower(opt.Theme)]
+	if !ok {
+		style = styles.Get(DefaultRenderTheme)
+	}
+
+	var css bytes.Buffer
+	if err := newFormatter(opt, "L").WriteCSS(&css, style); err != nil {
+		return "", err
+	}
+
+	// 多文件时逐个渲染，行号锚点加上文件序号避免冲突
+	var body strings.Builder
+	files := code.AllFiles()
+	for i, f := range files {
+		prefix := "L"
+		if code.IsMultiFile() {
+			prefix = fmt.Sprintf("F%d-L", i+1)
+			fmt.Fprintf(&body, "<h3 class=\"file\">%s</h3>\n", html.EscapeString(f.Name))
+		}
+		out, err := highlight(newFormatter(opt, prefix), style, f.Language, f.Content)
+		if err != nil {
+			return "", err
+		}
+		body.WriteString(out)
+	}
+
+	title := fmt.Sprintf("%s · %s", code.Hash, code.Language)
+	desc := fmt.Sprintf("%s shared by %s", code.Language, code.Author)
+
+	bg := "#fff"
+	if entry := style.Get(chroma.Background); entry.Background.IsSet() {
+		bg = entry.Background.String()
+	}
+
+	var page strings.Builder
+	page.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	page.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&page, "<title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprintf(&page, "<meta property=\"og:title\" content=\"%s\">\n", html.EscapeString(title))
+	fmt.Fprintf(&page, "<meta property=\"og:description\" content=\"%s\">\n", html.EscapeString(desc))
+	page.WriteString("<style>\n")
+	fmt.Fprintf(&page, "body { margin: 0; padding: 16px; background: %s; }\n", bg)
+	page.WriteString("pre { margin: 0; font-size: 14px; }\n")
+	page.WriteString("h3.file { margin: 16px 0 8px; font: 600 14px monospace; color: #888; }\n")
+	page.WriteString(css.String())
+	page.WriteString("</style>\n</head>\n<body>\n")
+	page.WriteString(body.String())
+	page.WriteString("</body>\n</html>\n")
+	return page.String(), nil
+}
+
+func newFormatter(opt RenderOptions, linePrefix string) *chromahtml.Formatter {
+	return chromahtml.New(
+		chromahtml.WithClasses(true),
+		chromahtml.WithLineNumbers(opt.LineNumbers),
+		chromahtml.WithLinkableLineNumbers(opt.LineNumbers, linePrefix),
+		chromahtml.TabWidth(4),
+	)
+}
+
+func highlight(formatter *chromahtml.Formatter, style *chroma.Style, lang, content string) (string, error) {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Analyse(content)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	it, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := formatter.Format(&buf, style, it); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
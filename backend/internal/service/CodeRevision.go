@@ -0,0 +1,249 @@
+// CodeShare 版本与 fork：同一 hash 下发布新版本，或 fork 为新的 hash
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const MaxRevisions = 50
+
+var (
+	ErrCodeRevisionNotFound = errors.New("revision not found")
+	ErrCodeTooManyRevisions = fmt.Errorf("at most %d revisions per code", MaxRevisions)
+	ErrCodeEncryptionMode   = errors.New("revision must keep the encryption mode of the code")
+)
+
+type CodeRevision struct {
+	Number     int             `json:"revision"`
+	Language   string          `json:"language"`
+	Content    string          `json:"content"`
+	Files      []CodeFile      `json:"files,omitempty"`
+	Encryption *CodeEncryption `json:"encryption,omitempty"`
+	CreatedAt  int64           `json:"created_at"`
+	Digest     string          `json:"-"`
+}
+
+// CodeRevisionInfo 为版本列表中的摘要信息
+type CodeRevisionInfo struct {
+	Number    int    `json:"revision"`
+	Language  string `json:"language"`
+	Size      int    `json:"size"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// CodeRevise 为发布新版本的参数
+type CodeRevise struct {
+	Language   string
+	Content    string
+	Files      []CodeFile
+	Encryption *CodeEncryption
+}
+
+func (c *Code) currentRevision(now int64) CodeRevision {
+	return CodeRevision{
+		Number:     c.Revision,
+		Language:   c.Language,
+		Content:    c.Content,
+		Files:      c.Files,
+		Encryption: c.Encryption,
+		CreatedAt:  now,
+	}
+}
+
+// AtRevision 返回指定版本的快照，n 为 0 时返回当前版本；
+// 查看历史版本时 c 需通过 GetHistory 读取，否则历史版本没有正文
+func (c *Code) AtRevision(n int) (*Code, error) {
+	if n == 0 || n == c.Revision {
+		return c, nil
+	}
+	for _, r := range c.Revisions {
+		if r.Number == n {
+			snap := *c
+			snap.Revision = r.Number
+			snap.Language = r.Language
+			snap.Content = r.Content
+			snap.Files = r.Files
+			snap.Encryption = r.Encryption
+			snap.Digest = r.Digest
+			return &snap, nil
+		}
+	}
+	return nil, ErrCodeRevisionNotFound
+}
+
+// RevisionInfos 返回版本摘要，按版本号升序
+func (c *Code) RevisionInfos() []CodeRevisionInfo {
+	infos := make([]CodeRevisionInfo, 0, len(c.Revisions))
+	for _, r := range c.Revisions {
+		infos = append(infos, CodeRevisionInfo{
+			Number:    r.Number,
+			Language:  r.Language,
+			Size:      int(contentSize(r.Content, r.Files)),
+			CreatedAt: r.CreatedAt,
+		})
+	}
+	return infos
+}
+
+// Revisions 读取片段用于展示版本列表，不返回内容，不计入浏览
+func (cs *CodeShare) Revisions(hash string) (*Code, bool) {
+	return cs.loadHistory(hash)
+}
+
+// Revise 由持有管理 token 的作者在原 hash 下发布新版本
+func (cs *CodeShare) Revise(hash, token string, rv *CodeRevise) (*Code, error) {
+	if err := validateContent(rv.Content, rv.Files, rv.Encryption); err != nil {
+		return nil, err
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	code, err := cs.owned(hash, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := code.applyRevision(rv, time.Now().Unix()); err != nil {
+		return nil, err
+	}
+
+	if err := cs.store.Put(code); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// applyRevision 将 rv 作为新版本写入 code，调用方需已校验内容
+func (c *Code) applyRevision(rv *CodeRevise, now int64) error {
+	if (rv.Encryption != nil) != c.Encrypted() {
+		return ErrCodeEncryptionMode
+	}
+	if len(c.Revisions) >= MaxRevisions {
+		return ErrCodeTooManyRevisions
+	}
+
+	// 语言为空时沿用当前语言，auto 时重新识别；多文件中未指定语言的文件总是识别
+	lang := rv.Language
+	if lang == "" {
+		lang = c.Language
+	}
+	resolveLanguages(&lang, rv.Content, "", rv.Files, rv.Encryption != nil)
+	c.Language = lang
+	c.Content = rv.Content
+	c.Files = rv.Files
+	c.Encryption = rv.Encryption
+	if c.IsMultiFile() {
+		c.Content = ""
+		c.Language = c.Files[0].Language
+	}
+	c.Revision++
+	c.Revisions = append(c.Revisions, c.currentRevision(now))
+	return nil
+}
+
+// Fork 以 hash 的当前版本为基础创建新片段；up.Content 为空时复制原内容。
+// 有浏览上限的片段不能 fork，避免绕过浏览次数限制
+func (cs *CodeShare) Fork(hash string, up *CodeUpload) (*UploadResult, error) {
+	parent, err := cs.Peek(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if up.Content == "" && len(up.Files) == 0 {
+		up.Content = parent.Content
+		up.Files = parent.Files
+		up.Encryption = parent.Encryption
+	}
+	if up.Language == "" {
+		up.Language = parent.Language
+	}
+	return cs.create(up, parent)
+}
+
+// Diff 返回同一片段两个版本之间的 unified diff
+func (cs *CodeShare) Diff(hash string, from, to int) (string, error) {
+	code, err := cs.peek(hash, cs.loadHistory)
+	if err != nil {
+		return "", err
+	}
+	if code.Encrypted() {
+		return "", ErrCodeEncrypted
+	}
+
+	a, err := code.AtRevision(from)
+	if err != nil {
+		return "", err
+	}
+	b, err := code.AtRevision(to)
+	if err != nil {
+		return "", err
+	}
+
+	// 按文件名逐个比较；单文件片段两端都只有一个文件，直接比较内容
+	oldFiles, newFiles := a.AllFiles(), b.AllFiles()
+	if !a.IsMultiFile() && !b.IsMultiFile() {
+		oldFiles[0].Name, newFiles[0].Name = hash, hash
+	}
+
+	newByName := make(map[string]string, len(newFiles))
+	for _, f := range newFiles {
+		newByName[f.Name] = f.Content
+	}
+	oldByName := make(map[string]bool, len(oldFiles))
+
+	var out strings.Builder
+	write := func(name, before, after string, fromExists, toExists bool) error {
+		from, to := fmt.Sprintf("a/%s@%d", name, a.Revision), fmt.Sprintf("b/%s@%d", name, b.Revision)
+		if !fromExists {
+			from = "/dev/null"
+		}
+		if !toExists {
+			to = "/dev/null"
+		}
+		d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        splitLines(before),
+			B:        splitLines(after),
+			FromFile: from,
+			ToFile:   to,
+			Context:  3,
+		})
+		out.WriteString(d)
+		return err
+	}
+
+	for _, f := range oldFiles {
+		oldByName[f.Name] = true
+		after, ok := newByName[f.Name]
+		if err := write(f.Name, f.Content, after, true, ok); err != nil {
+			return "", err
+		}
+	}
+	for _, f := range newFiles {
+		if oldByName[f.Name] {
+			continue
+		}
+		if err := write(f.Name, "", f.Content, false, true); err != nil {
+			return "", err
+		}
+	}
+	return out.String(), nil
+}
+
+// 按行切分并保留换行符，末尾缺少换行时补齐，空内容返回空切片
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] += "\n"
+	}
+	return lines
+}
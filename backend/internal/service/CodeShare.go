@@ -1,35 +1,166 @@
-// 并发安全 + TTL + 基于 FIFO slice 实现的LRU
+// 并发安全 + TTL + O(1) LRU（条数 / 字节数）+ 可替换的存储后端（内存 / bbolt）
 package service
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	MaxEntries     = 1000
 	MaxContentSize = 100000
+	MaxTotalBytes  = 64 << 20
+
+	DefaultCodeDataPath = "data/codeshare.db"
+)
+
+var (
+	ErrCodeNotFound     = errors.New("code not found or expired")
+	ErrCodeTooLarge     = errors.New("content too large")
+	ErrCodeInvalidViews = errors.New("max_views must not be negative")
+	ErrCodeForbidden    = errors.New("invalid management token")
+	ErrCodeViewLimited  = errors.New("code has a view limit, read it through the code or raw endpoint")
 )
 
+type CodeShareConfig struct {
+	// Storage 选择存储后端：memory（默认）或 bolt
+	Storage string
+	// DataPath 为 bolt 后端的数据文件路径
+	DataPath string
+	// MaxEntries 与 MaxBytes 均按去重后的正文计算
+	MaxEntries int
+	// MaxBytes 为所有代码内容的总字节上限
+	MaxBytes int64
+	// TTL 为有效期策略，零值字段使用默认值
+	TTL TTLPolicy
+}
+
 type CodeShare struct {
-	mu    sync.RWMutex
-	store map[string]*Code
-	order []string
+	// mu 串行化"读 - 改 - 写"类操作（如浏览计数），普通读取不经过它
+	mu         sync.Mutex
+	store      CodeStore
+	maxEntries int
+	maxBytes   int64
+	ttl        TTLPolicy
+	// comments 管理评论的实时订阅者
+	comments *commentHub
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type CodeShareStats struct {
+	CodeStoreStats
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	MaxEntries int    `json:"max_entries"`
+	MaxBytes   int64  `json:"max_bytes"`
 }
 
 type Code struct {
-	Author      string `json:"author"`
-	Language    string `json:"language"`
-	Content     string `json:"content"`
-	Hash        string `json:"hash"`
-	DestroyTime int64  `json:"destroy_time"`
+	Author   string `json:"author"`
+	Language string `json:"language"`
+	// 加密片段中为 base64 密文
+	Content    string          `json:"content"`
+	Encryption *CodeEncryption `json:"encryption,omitempty"`
+	// 多文件片段时 Files 非空，Content 为空，Language 取第一个文件的语言
+	Files []CodeFile `json:"files,omitempty"`
+	Hash  string     `json:"hash"`
+	// Digest 为当前正文的 SHA-256，相同正文只存储一份
+	Digest string `json:"digest"`
+	// DestroyTime 为 0 表示永不过期（置顶）
+	DestroyTime int64 `json:"destroy_time"`
+	// MaxViews 为 0 表示不限制浏览次数，达到上限后立即删除
+	MaxViews int `json:"max_views,omitempty"`
+	Views    int `json:"views"`
+	// Public 为 true 时出现在公开列表中
+	Public    bool  `json:"public,omitempty"`
+	CreatedAt int64 `json:"created_at"`
+
+	// Parent 为 fork 来源的 hash 及其当时的版本号
+	Parent         string `json:"parent,omitempty"`
+	ParentRevision int    `json:"parent_revision,omitempty"`
+	// Revision 为当前版本号，Revisions 保存全部历史版本（含当前）
+	Revision  int            `json:"revision"`
+	Revisions []CodeRevision `json:"-"`
+	// 管理 token 只保存摘要
+	TokenHash string `json:"-"`
+	// 行评论，通过 /codeshare/comments 单独获取
+	Comments []CodeComment `json:"-"`
+}
+
+// CodeUpload 为上传参数
+type CodeUpload struct {
+	Author   string
+	Language string
+	Content  string
+	Files    []CodeFile
+	// Language 为空或 auto 时自动识别，Filename 仅作为识别依据
+	Filename string
+	// FormatGo 为 true 时对 Go 内容执行 gofmt 并返回检查结果
+	FormatGo bool
+	// TTL 为 0 时使用默认值，TTLNever 表示永不过期，需有置顶权限
+	TTL int64
+	// AdminToken 为置顶所需的 admin token，见 TTLPolicy.AdminToken
+	AdminToken string
+	Encryption *CodeEncryption
+	MaxViews   int
+	Public     bool
 }
 
-func NewCodeShareService() *CodeShare {
+// UploadResult 为上传结果，管理 Token 只在此处返回一次
+type UploadResult struct {
+	Code  *Code
+	Token string
+	// Detected 为自动识别出的语言，未触发识别时为空
+	Detected []LanguageDetection
+	// GoChecks 为 FormatGo 时各 Go 文件的检查结果
+	GoChecks []*GoCheckResult
+}
+
+// Encrypted 表示内容为客户端密文，服务端无法读取
+func (c *Code) Encrypted() bool {
+	return c.Encryption != nil
+}
+
+func NewCodeShareService(cfg CodeShareConfig) (*CodeShare, error) {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = MaxEntries
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = MaxTotalBytes
+	}
+	if cfg.DataPath == "" {
+		cfg.DataPath = DefaultCodeDataPath
+	}
+	if err := cfg.TTL.normalize(); err != nil {
+		return nil, err
+	}
+
+	var store CodeStore
+	switch cfg.Storage {
+	case "", CodeStorageMemory:
+		store = newMemoryCodeStore(cfg.MaxEntries, cfg.MaxBytes)
+	case CodeStorageBolt:
+		s, err := newBoltCodeStore(cfg.DataPath, cfg.MaxEntries, cfg.MaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		store = s
+	default:
+		return nil, fmt.Errorf("unknown codeshare storage %q", cfg.Storage)
+	}
 
 	cs := &CodeShare{
-		store: make(map[string]*Code),
-		order: make([]string, 0, MaxEntries),
+		store:      store,
+		maxEntries: cfg.MaxEntries,
+		maxBytes:   cfg.MaxBytes,
+		ttl:        cfg.TTL,
+		comments:   newCommentHub(),
 	}
 
 	go func() {
@@ -38,16 +169,33 @@ func NewCodeShareService() *CodeShare {
 		}
 	}()
 
-	return cs
+	return cs, nil
 }
 
 // 上传
-func (cs *CodeShare) Upload(author, lang, content string, ttl int64) *Code {
-	if len(content) > MaxContentSize {
-		return nil
+func (cs *CodeShare) Upload(up *CodeUpload) (*UploadResult, error) {
+	return cs.create(up, nil)
+}
+
+// create 新建片段，parent 不为空时作为其 fork
+func (cs *CodeShare) create(up *CodeUpload, parent *Code) (*UploadResult, error) {
+	if err := validateContent(up.Content, up.Files, up.Encryption); err != nil {
+		return nil, err
+	}
+	if up.MaxViews < 0 {
+		return nil, ErrCodeInvalidViews
+	}
+	detected := resolveLanguages(&up.Language, up.Content, up.Filename, up.Files, up.Encryption != nil)
+	var checks []*GoCheckResult
+	if up.FormatGo && up.Encryption == nil {
+		checks = formatGoSources(&up.Content, up.Language, up.Files)
 	}
 
-	destroy := time.Now().Unix() + ttl
+	now := time.Now().Unix()
+	destroy, err := cs.destroyTime(up.TTL, now, up.AdminToken)
+	if err != nil {
+		return nil, err
+	}
 	/*
 		目前用户较少，暂时不使用复杂的哈希算法，因为后缀过长
 		data := fmt.Sprintf("%s|%s|%s|%d", author, lang, content, destroy)
@@ -57,69 +205,189 @@ func (cs *CodeShare) Upload(author, lang, content string, ttl int64) *Code {
 	// 简单哈希
 
 	hash := GetHash(10)
+	token := GetHash(24)
 	code := &Code{
-		Author:      author,
-		Language:    lang,
-		Content:     content,
+		Author:      up.Author,
+		Language:    up.Language,
+		Content:     up.Content,
+		Encryption:  up.Encryption,
+		Files:       up.Files,
 		Hash:        hash,
 		DestroyTime: destroy,
+		MaxViews:    up.MaxViews,
+		Public:      up.Public,
+		CreatedAt:   now,
+		Revision:    1,
+		TokenHash:   hashToken(token),
+	}
+	if code.IsMultiFile() {
+		code.Content = ""
+		code.Language = code.Files[0].Language
 	}
+	if parent != nil {
+		code.Parent = parent.Hash
+		code.ParentRevision = parent.Revision
+	}
+	code.Revisions = []CodeRevision{code.currentRevision(now)}
 
-	cs.mu.Lock()
-	defer cs.mu.Unlock()
+	if err := cs.store.Put(code); err != nil {
+		return nil, err
+	}
 
-	// 内存存储
-	if len(cs.store) >= MaxEntries {
-		oldest := cs.order[0]
-		delete(cs.store, oldest)
-		cs.order = cs.order[1:]
+	return &UploadResult{Code: code, Token: token, Detected: detected, GoChecks: checks}, nil
+}
+
+// 校验内容大小；加密内容按明文长度计算，base64 膨胀不计入限制
+func validateContent(content string, files []CodeFile, enc *CodeEncryption) error {
+	if len(files) > 0 {
+		if enc != nil {
+			return ErrCodeEncryptedFiles
+		}
+		size, err := validateFiles(files)
+		if err != nil {
+			return err
+		}
+		if size > MaxContentSize {
+			return ErrCodeTooLarge
+		}
+		return nil
+	}
+	if content == "" {
+		return ErrCodeEmpty
 	}
-	cs.store[hash] = code
-	cs.order = append(cs.order, hash)
 
-	return code
+	size := len(content)
+	if enc != nil {
+		n, err := enc.validate(content)
+		if err != nil {
+			return err
+		}
+		size = n
+	}
+	if size > MaxContentSize {
+		return ErrCodeTooLarge
+	}
+	return nil
 }
 
-// 获取
+// 获取，计入一次浏览；有浏览上限的片段在达到上限时被删除
 func (cs *CodeShare) Get(hash string) (*Code, bool) {
-	cs.mu.Lock()
-	code, ok := cs.store[hash]
+	return cs.get(hash, cs.load)
+}
 
-	if ok {
-		// 1. 从 order 中移除旧位置
-		for i := range cs.order {
-			if cs.order[i] == hash {
-				cs.order = append(cs.order[:i], cs.order[i+1:]...)
-				break
-			}
-		}
-		// 2. 放到末尾（表示最近使用）
-		cs.order = append(cs.order, hash)
+// GetRevision 获取指定版本，计入一次浏览；n 为 0 时等同 Get
+func (cs *CodeShare) GetRevision(hash string, n int) (*Code, error) {
+	load := cs.load
+	if n != 0 {
+		load = cs.loadHistory
 	}
+	code, ok := cs.get(hash, load)
+	if !ok {
+		return nil, ErrCodeNotFound
+	}
+	return code.AtRevision(n)
+}
 
-	cs.mu.Unlock()
+func (cs *CodeShare) get(hash string, load func(string) (*Code, bool)) (*Code, bool) {
+	code, ok := load(hash)
+	if ok && code.MaxViews > 0 {
+		code, ok = cs.view(hash, load)
+	}
 
-	if ok && time.Now().Unix() <= code.DestroyTime {
+	if ok {
+		cs.hits.Add(1)
 		return code, true
 	}
 
+	cs.misses.Add(1)
 	return nil, false
 }
 
-// 清理过期的
-func (cs *CodeShare) cleanExpired() {
-	now := time.Now().Unix()
+// Peek 读取片段用于渲染、diff、fork 等派生用途，不计入浏览；
+// 有浏览上限的片段只能通过计数的接口读取，避免链接预览消耗次数或绕过上限
+func (cs *CodeShare) Peek(hash string) (*Code, error) {
+	return cs.peek(hash, cs.load)
+}
+
+func (cs *CodeShare) peek(hash string, load func(string) (*Code, bool)) (*Code, error) {
+	code, ok := load(hash)
+	if !ok {
+		cs.misses.Add(1)
+		return nil, ErrCodeNotFound
+	}
+	if code.MaxViews > 0 {
+		return nil, ErrCodeViewLimited
+	}
+	cs.hits.Add(1)
+	return code, nil
+}
+
+// view 原子地增加浏览次数，返回本次浏览看到的内容
+func (cs *CodeShare) view(hash string, load func(string) (*Code, bool)) (*Code, bool) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
-	newOrder := cs.order[:0]
-	for _, h := range cs.order {
-		c := cs.store[h]
-		if c == nil || c.DestroyTime < now {
-			delete(cs.store, h)
-			continue
-		}
-		newOrder = append(newOrder, h)
+	// 加锁后重新读取，避免并发浏览超出上限
+	code, ok := load(hash)
+	if !ok {
+		return nil, false
+	}
+
+	code.Views++
+	var err error
+	if code.Views >= code.MaxViews {
+		err = cs.store.Delete(hash)
+	} else {
+		err = cs.store.Put(code)
+	}
+	if err != nil {
+		log.Println("codeshare view err:", err)
+		return nil, false
+	}
+	return code, true
+}
+
+// load 读取未过期的片段，不计入浏览与命中统计；历史版本只有摘要
+func (cs *CodeShare) load(hash string) (*Code, bool) {
+	return cs.fetch(hash, cs.store.Get)
+}
+
+// loadHistory 与 load 相同，但同时读取历史版本的正文，供版本列表、diff 等使用
+func (cs *CodeShare) loadHistory(hash string) (*Code, bool) {
+	return cs.fetch(hash, cs.store.GetHistory)
+}
+
+func (cs *CodeShare) fetch(hash string, get func(string) (*Code, bool, error)) (*Code, bool) {
+	code, ok, err := get(hash)
+	if err != nil {
+		log.Println("codeshare get err:", err)
+		return nil, false
+	}
+	if !ok || code.Expired(time.Now().Unix()) {
+		return nil, false
+	}
+	return code, true
+}
+
+// Stats 返回命中 / 未命中 / 淘汰计数及当前容量
+func (cs *CodeShare) Stats() CodeShareStats {
+	return CodeShareStats{
+		CodeStoreStats: cs.store.Stats(),
+		Hits:           cs.hits.Load(),
+		Misses:         cs.misses.Load(),
+		MaxEntries:     cs.maxEntries,
+		MaxBytes:       cs.maxBytes,
+	}
+}
+
+// Close 关闭底层存储
+func (cs *CodeShare) Close() error {
+	return cs.store.Close()
+}
+
+// 清理过期的
+func (cs *CodeShare) cleanExpired() {
+	if err := cs.store.DeleteExpired(time.Now().Unix()); err != nil {
+		log.Println("codeshare clean expired err:", err)
 	}
-	cs.order = newOrder
 }
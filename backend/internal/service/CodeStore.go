@@ -0,0 +1,195 @@
+// CodeShare 的存储层：内存实现 + 接口定义
+package service
+
+import (
+	"sync"
+)
+
+const (
+	CodeStorageMemory = "memory"
+	CodeStorageBolt   = "bolt"
+)
+
+// CodeStore 负责 Code 的持久化与淘汰，实现需要自身保证并发安全
+type CodeStore interface {
+	// Put 写入（或覆盖）一条记录，超出容量时由实现负责淘汰
+	Put(code *Code) error
+	// Get 读取一条记录，并将其标记为最近使用；只读取当前版本的正文，历史版本只有摘要
+	Get(hash string) (*Code, bool, error)
+	// GetHistory 与 Get 相同，但同时读取全部历史版本的正文
+	GetHistory(hash string) (*Code, bool, error)
+	Delete(hash string) error
+	// List 返回元数据满足 match 的记录（含当前版本正文），不影响 LRU 顺序；match 看到的记录不含正文
+	List(match func(meta *Code) bool) ([]*Code, error)
+	// DeleteExpired 删除在 now 时已过期的记录，置顶记录除外
+	DeleteExpired(now int64) error
+	Stats() CodeStoreStats
+	Close() error
+}
+
+type CodeStoreStats struct {
+	Entries   int    `json:"entries"`
+	Blobs     int    `json:"blobs"`
+	Pinned    int    `json:"pinned"`
+	Bytes     int64  `json:"bytes"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// 基于 map + LRU 索引的内存存储，正文按摘要去重并引用计数
+type memoryCodeStore struct {
+	mu    sync.Mutex
+	store map[string]*Code // 只含摘要的元数据
+	blobs map[string]*memoryBlob
+	lru   *lruIndex
+}
+
+type memoryBlob struct {
+	body codeBody
+	refs int
+}
+
+func newMemoryCodeStore(maxEntries int, maxBytes int64) *memoryCodeStore {
+	return &memoryCodeStore{
+		store: make(map[string]*Code),
+		blobs: make(map[string]*memoryBlob),
+		lru:   newLRUIndex(maxEntries, maxBytes),
+	}
+}
+
+func (s *memoryCodeStore) Put(code *Code) error {
+	meta, bodies := code.split()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range meta.digests() {
+		if _, ok := bodies[d]; !ok && s.blobs[d] == nil {
+			return ErrCodeBodyMissing
+		}
+	}
+	// 先引用新正文再释放旧正文，未变化的正文不会被删除后重建
+	for _, d := range meta.digests() {
+		s.acquire(d, bodies[d])
+	}
+	if old, ok := s.store[meta.Hash]; ok {
+		s.release(old)
+	}
+	s.store[meta.Hash] = meta
+
+	s.lru.add(meta.Hash, meta.Pinned(), meta.commentBytes())
+	return s.lru.evict(meta.Hash, func(h string) error {
+		s.release(s.store[h])
+		delete(s.store, h)
+		return nil
+	})
+}
+
+func (s *memoryCodeStore) Get(hash string) (*Code, bool, error) {
+	return s.get(hash, false)
+}
+
+func (s *memoryCodeStore) GetHistory(hash string) (*Code, bool, error) {
+	return s.get(hash, true)
+}
+
+func (s *memoryCodeStore) get(hash string, history bool) (*Code, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, ok := s.store[hash]
+	if !ok {
+		return nil, false, nil
+	}
+	s.lru.touch(hash)
+
+	code, err := meta.join(s.body, history)
+	if err != nil {
+		return nil, false, err
+	}
+	return code, true, nil
+}
+
+func (s *memoryCodeStore) List(match func(meta *Code) bool) ([]*Code, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var codes []*Code
+	for _, meta := range s.store {
+		if !match(meta) {
+			continue
+		}
+		code, err := meta.join(s.body, false)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func (s *memoryCodeStore) Delete(hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if meta, ok := s.store[hash]; ok {
+		s.release(meta)
+		delete(s.store, hash)
+	}
+	s.lru.remove(hash)
+	return nil
+}
+
+func (s *memoryCodeStore) DeleteExpired(now int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for h, c := range s.store {
+		if c.Expired(now) {
+			s.release(c)
+			delete(s.store, h)
+			s.lru.remove(h)
+		}
+	}
+	return nil
+}
+
+func (s *memoryCodeStore) body(digest string) (codeBody, bool) {
+	b, ok := s.blobs[digest]
+	if !ok {
+		return codeBody{}, false
+	}
+	return b.body, true
+}
+
+func (s *memoryCodeStore) acquire(digest string, body codeBody) {
+	if b, ok := s.blobs[digest]; ok {
+		b.refs++
+		return
+	}
+	s.blobs[digest] = &memoryBlob{body: body, refs: 1}
+	s.lru.charge(body.size(), 1)
+}
+
+// release 释放元数据引用的全部正文，引用归零的正文被删除
+func (s *memoryCodeStore) release(meta *Code) {
+	for _, d := range meta.digests() {
+		b, ok := s.blobs[d]
+		if !ok {
+			continue
+		}
+		if b.refs--; b.refs == 0 {
+			delete(s.blobs, d)
+			s.lru.charge(-b.body.size(), -1)
+		}
+	}
+}
+
+func (s *memoryCodeStore) Stats() CodeStoreStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.stats()
+}
+
+func (s *memoryCodeStore) Close() error {
+	return nil
+}
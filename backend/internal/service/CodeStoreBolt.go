@@ -0,0 +1,463 @@
+// CodeShare 的磁盘存储：基于 bbolt 的嵌入式 KV
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	codeBucket = []byte("codes")
+	// 正文按摘要存储，引用计数与正文字节数单独保存，避免更新计数时重写正文
+	blobBucket    = []byte("blobs")
+	blobRefBucket = []byte("blobrefs")
+)
+
+// 数据落在 bbolt 文件中，LRU 索引只保存在内存里，
+// 重启后按 DestroyTime 重建（先过期的先被淘汰，置顶记录不淘汰）
+type boltCodeStore struct {
+	db *bolt.DB
+
+	mu  sync.Mutex
+	lru *lruIndex
+}
+
+func newBoltCodeStore(path string, maxEntries int, maxBytes int64) (*boltCodeStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &boltCodeStore{
+		db:  db,
+		lru: newLRUIndex(maxEntries, maxBytes),
+	}
+	if err := s.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// 启动时清掉过期数据，按元数据重新统计引用计数、清理无人引用的正文，并重建淘汰顺序；
+// 写入失败后也通过它让内存索引与磁盘保持一致
+func (s *boltCodeStore) load() error {
+	now := time.Now().Unix()
+	lru := newLRUIndex(s.lru.maxEntries, s.lru.maxBytes)
+	lru.evictions = s.lru.evictions
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{codeBucket, blobBucket, blobRefBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		t := s.tx(tx, lru)
+
+		var entries []*Code
+		var stale [][]byte
+		err := t.codes.ForEach(func(k, v []byte) error {
+			code, err := decodeCode(v)
+			if err != nil || code.Expired(now) {
+				stale = append(stale, append([]byte(nil), k...))
+				return nil
+			}
+			for _, d := range code.digests() {
+				if t.blobs.Get([]byte(d)) == nil {
+					stale = append(stale, append([]byte(nil), k...))
+					return nil
+				}
+			}
+			entries = append(entries, code)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if err := t.codes.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		counts := make(map[string]uint64)
+		for _, code := range entries {
+			for _, d := range code.digests() {
+				counts[d]++
+			}
+		}
+		if err := t.recount(counts); err != nil {
+			return err
+		}
+
+		// 按过期时间升序插入，最早过期的位于链表尾部；容量缩小时多出来的直接淘汰
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].DestroyTime < entries[j].DestroyTime
+		})
+		for _, code := range entries {
+			lru.add(code.Hash, code.Pinned(), code.commentBytes())
+		}
+		return lru.evict("", t.drop)
+	})
+	if err != nil {
+		return err
+	}
+	s.lru = lru
+	return nil
+}
+
+func (s *boltCodeStore) Put(code *Code) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.update(func(t boltTx) error {
+		return t.put(code)
+	})
+}
+
+func (s *boltCodeStore) Get(hash string) (*Code, bool, error) {
+	return s.get(hash, false)
+}
+
+func (s *boltCodeStore) GetHistory(hash string) (*Code, bool, error) {
+	return s.get(hash, true)
+}
+
+func (s *boltCodeStore) get(hash string, history bool) (*Code, bool, error) {
+	var code *Code
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c, err := s.tx(tx, nil).get(hash, history)
+		code = c
+		return err
+	})
+	if err != nil || code == nil {
+		return nil, false, err
+	}
+
+	s.mu.Lock()
+	s.lru.touch(hash)
+	s.mu.Unlock()
+
+	return code, true, nil
+}
+
+func (s *boltCodeStore) List(match func(meta *Code) bool) ([]*Code, error) {
+	var codes []*Code
+	err := s.db.View(func(tx *bolt.Tx) error {
+		t := s.tx(tx, nil)
+		return t.codes.ForEach(func(k, v []byte) error {
+			meta, err := decodeCode(v)
+			if err != nil || !match(meta) {
+				return nil
+			}
+			code, err := t.join(meta, false)
+			if err != nil {
+				return err
+			}
+			codes = append(codes, code)
+			return nil
+		})
+	})
+	return codes, err
+}
+
+func (s *boltCodeStore) Delete(hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.update(func(t boltTx) error {
+		if err := t.drop(hash); err != nil {
+			return err
+		}
+		t.lru.remove(hash)
+		return nil
+	})
+}
+
+func (s *boltCodeStore) DeleteExpired(now int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.update(func(t boltTx) error {
+		var expired []string
+		err := t.codes.ForEach(func(k, v []byte) error {
+			code, err := decodeCode(v)
+			if err != nil || code.Expired(now) {
+				expired = append(expired, string(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, h := range expired {
+			if err := t.drop(h); err != nil {
+				return err
+			}
+			t.lru.remove(h)
+		}
+		return nil
+	})
+}
+
+// update 在写事务中修改数据与内存索引；事务回滚时索引可能已被修改，按磁盘重新加载
+func (s *boltCodeStore) update(fn func(t boltTx) error) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return fn(s.tx(tx, s.lru))
+	})
+	if err != nil {
+		if lerr := s.load(); lerr != nil {
+			log.Println("codeshare reload err:", lerr)
+		}
+	}
+	return err
+}
+
+func (s *boltCodeStore) tx(tx *bolt.Tx, lru *lruIndex) boltTx {
+	return boltTx{
+		codes: tx.Bucket(codeBucket),
+		blobs: tx.Bucket(blobBucket),
+		refs:  tx.Bucket(blobRefBucket),
+		lru:   lru,
+	}
+}
+
+// boltTx 封装一个事务内对元数据与正文的操作
+type boltTx struct {
+	codes, blobs, refs *bolt.Bucket
+	lru                *lruIndex
+}
+
+func (t boltTx) put(code *Code) error {
+	meta, bodies := code.split()
+	data, err := encodeCode(meta)
+	if err != nil {
+		return err
+	}
+
+	// 先引用新正文再释放旧正文，未变化的正文不会被删除后重建
+	for _, d := range meta.digests() {
+		body, ok := bodies[d]
+		if err := t.acquire(d, body, ok); err != nil {
+			return err
+		}
+	}
+	if err := t.release(meta.Hash); err != nil {
+		return err
+	}
+	if err := t.codes.Put([]byte(meta.Hash), data); err != nil {
+		return err
+	}
+
+	t.lru.add(meta.Hash, meta.Pinned(), meta.commentBytes())
+	return t.lru.evict(meta.Hash, t.drop)
+}
+
+func (t boltTx) get(hash string, history bool) (*Code, error) {
+	v := t.codes.Get([]byte(hash))
+	if v == nil {
+		return nil, nil
+	}
+	meta, err := decodeCode(v)
+	if err != nil {
+		return nil, err
+	}
+	return t.join(meta, history)
+}
+
+// join 读取并解码正文；同一摘要（如当前版本与最后一个历史版本）只解码一次
+func (t boltTx) join(meta *Code, history bool) (*Code, error) {
+	var derr error
+	decoded := make(map[string]codeBody)
+	code, err := meta.join(func(d string) (codeBody, bool) {
+		if b, ok := decoded[d]; ok {
+			return b, true
+		}
+		v := t.blobs.Get([]byte(d))
+		if v == nil {
+			return codeBody{}, false
+		}
+		var b codeBody
+		if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&b); err != nil {
+			derr = err
+			return codeBody{}, false
+		}
+		decoded[d] = b
+		return b, true
+	}, history)
+	if derr != nil {
+		return nil, derr
+	}
+	return code, err
+}
+
+// drop 删除元数据并释放其引用的正文，不修改 LRU 顺序
+func (t boltTx) drop(hash string) error {
+	if err := t.release(hash); err != nil {
+		return err
+	}
+	return t.codes.Delete([]byte(hash))
+}
+
+// acquire 增加正文引用；hasBody 为 false 表示调用方没有读取正文，此时正文必须已存在
+func (t boltTx) acquire(digest string, body codeBody, hasBody bool) error {
+	key := []byte(digest)
+	if refs, size, ok := t.ref(key); ok {
+		return t.setRef(key, refs+1, size)
+	}
+	if !hasBody {
+		return ErrCodeBodyMissing
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(body); err != nil {
+		return err
+	}
+	if err := t.blobs.Put(key, buf.Bytes()); err != nil {
+		return err
+	}
+	size := body.size()
+	t.lru.charge(size, 1)
+	return t.setRef(key, 1, size)
+}
+
+// release 释放已存储元数据引用的全部正文，引用归零的正文被删除
+func (t boltTx) release(hash string) error {
+	v := t.codes.Get([]byte(hash))
+	if v == nil {
+		return nil
+	}
+	meta, err := decodeCode(v)
+	if err != nil {
+		// 无法解析的记录不再计入引用，由下次加载时统一清理
+		return nil
+	}
+
+	for _, d := range meta.digests() {
+		key := []byte(d)
+		refs, size, ok := t.ref(key)
+		if !ok {
+			continue
+		}
+		if refs > 1 {
+			if err := t.setRef(key, refs-1, size); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := t.blobs.Delete(key); err != nil {
+			return err
+		}
+		if err := t.refs.Delete(key); err != nil {
+			return err
+		}
+		t.lru.charge(-size, -1)
+	}
+	return nil
+}
+
+// recount 用 counts 覆盖引用计数，删除未被引用的正文
+func (t boltTx) recount(counts map[string]uint64) error {
+	var unused [][]byte
+	err := t.blobs.ForEach(func(k, v []byte) error {
+		if counts[string(k)] == 0 {
+			unused = append(unused, append([]byte(nil), k...))
+			return nil
+		}
+		size, ok := int64(0), false
+		if _, size, ok = t.ref(k); !ok {
+			var b codeBody
+			if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&b); err != nil {
+				return err
+			}
+			size = b.size()
+		}
+		if err := t.setRef(k, counts[string(k)], size); err != nil {
+			return err
+		}
+		t.lru.charge(size, 1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range unused {
+		if err := t.blobs.Delete(k); err != nil {
+			return err
+		}
+	}
+	var orphans [][]byte
+	err = t.refs.ForEach(func(k, _ []byte) error {
+		if counts[string(k)] == 0 {
+			orphans = append(orphans, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range orphans {
+		if err := t.refs.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 引用计数记录：8 字节引用数 + 8 字节正文字节数
+func (t boltTx) ref(key []byte) (uint64, int64, bool) {
+	v := t.refs.Get(key)
+	if len(v) != 16 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint64(v), int64(binary.BigEndian.Uint64(v[8:])), true
+}
+
+func (t boltTx) setRef(key []byte, refs uint64, size int64) error {
+	v := make([]byte, 16)
+	binary.BigEndian.PutUint64(v, refs)
+	binary.BigEndian.PutUint64(v[8:], uint64(size))
+	return t.refs.Put(key, v)
+}
+
+func (s *boltCodeStore) Stats() CodeStoreStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.stats()
+}
+
+func (s *boltCodeStore) Close() error {
+	return s.db.Close()
+}
+
+func encodeCode(code *Code) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(code); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeCode(data []byte) (*Code, error) {
+	var code Code
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&code); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestBoltStore(t *testing.T, path string, maxEntries int, maxBytes int64) *boltCodeStore {
+	t.Helper()
+	s, err := newBoltCodeStore(path, maxEntries, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// testStores 返回两种存储实现，用同一组用例验证
+func testStores(t *testing.T, maxEntries int, maxBytes int64) map[string]CodeStore {
+	return map[string]CodeStore{
+		CodeStorageMemory: newMemoryCodeStore(maxEntries, maxBytes),
+		CodeStorageBolt:   newTestBoltStore(t, filepath.Join(t.TempDir(), "code.db"), maxEntries, maxBytes),
+	}
+}
+
+func testCode(hash, content string, destroy int64) *Code {
+	return &Code{Hash: hash, Language: "go", Content: content, DestroyTime: destroy}
+}
+
+func mustPut(t *testing.T, s CodeStore, code *Code) {
+	t.Helper()
+	if err := s.Put(code); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCodeStoreRefcount(t *testing.T) {
+	future := time.Now().Unix() + 3600
+	type step struct {
+		put    *Code
+		delete string
+		blobs  int
+		bytes  int64
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "shared body freed with last reference",
+			steps: []step{
+				{put: testCode("a", "same", future), blobs: 1, bytes: 4},
+				{put: testCode("b", "same", future), blobs: 1, bytes: 4},
+				{delete: "a", blobs: 1, bytes: 4},
+				{delete: "b", blobs: 0, bytes: 0},
+			},
+		},
+		{
+			name: "overwrite releases old body",
+			steps: []step{
+				{put: testCode("a", "old", future), blobs: 1, bytes: 3},
+				{put: testCode("a", "newer", future), blobs: 1, bytes: 5},
+				{delete: "a", blobs: 0, bytes: 0},
+			},
+		},
+		{
+			name: "revisions reference bodies",
+			steps: []step{
+				{put: &Code{Hash: "a", Content: "v2", DestroyTime: future, Revision: 2, Revisions: []CodeRevision{
+					{Number: 1, Content: "v1"}, {Number: 2, Content: "v2"},
+				}}, blobs: 2, bytes: 4},
+				{put: testCode("b", "v1", future), blobs: 2, bytes: 4},
+				{delete: "a", blobs: 1, bytes: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for name, s := range testStores(t, 0, 0) {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				for i, st := range tt.steps {
+					if st.put != nil {
+						mustPut(t, s, st.put)
+					} else if err := s.Delete(st.delete); err != nil {
+						t.Fatal(err)
+					}
+					if got := s.Stats(); got.Blobs != st.blobs || got.Bytes != st.bytes {
+						t.Fatalf("step %d: blobs/bytes = %d/%d, want %d/%d", i, got.Blobs, got.Bytes, st.blobs, st.bytes)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCodeStoreEviction(t *testing.T) {
+	future := time.Now().Unix() + 3600
+	tests := []struct {
+		name    string
+		codes   []*Code
+		touch   string // 在最后一条写入前读取
+		kept    []string
+		evicted []string
+	}{
+		{
+			name:    "least recently used",
+			codes:   []*Code{testCode("a", "1", future), testCode("b", "2", future), testCode("c", "3", future)},
+			kept:    []string{"b", "c"},
+			evicted: []string{"a"},
+		},
+		{
+			name:    "get refreshes",
+			codes:   []*Code{testCode("a", "1", future), testCode("b", "2", future), testCode("c", "3", future)},
+			touch:   "a",
+			kept:    []string{"a", "c"},
+			evicted: []string{"b"},
+		},
+		{
+			name:    "pinned kept",
+			codes:   []*Code{testCode("p", "0", 0), testCode("a", "1", future), testCode("b", "2", future)},
+			kept:    []string{"p", "b"},
+			evicted: []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		for name, s := range testStores(t, 2, 0) {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				for i, code := range tt.codes {
+					if i == len(tt.codes)-1 && tt.touch != "" {
+						if _, ok, _ := s.Get(tt.touch); !ok {
+							t.Fatalf("get %q: not found", tt.touch)
+						}
+					}
+					mustPut(t, s, code)
+				}
+				for _, h := range tt.kept {
+					if _, ok, err := s.Get(h); !ok || err != nil {
+						t.Errorf("%q should be kept (err %v)", h, err)
+					}
+				}
+				for _, h := range tt.evicted {
+					if _, ok, _ := s.Get(h); ok {
+						t.Errorf("%q should be evicted", h)
+					}
+				}
+				if got := s.Stats().Evictions; got != uint64(len(tt.evicted)) {
+					t.Errorf("evictions = %d, want %d", got, len(tt.evicted))
+				}
+			})
+		}
+	}
+}
+
+func TestBoltCodeStoreReload(t *testing.T) {
+	now := time.Now().Unix()
+	path := filepath.Join(t.TempDir(), "code.db")
+
+	s, err := newBoltCodeStore(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustPut(t, s, testCode("late", "shared", now+300))
+	mustPut(t, s, testCode("early", "shared", now+100))
+	mustPut(t, s, testCode("mid", "other", now+200))
+	mustPut(t, s, testCode("pinned", "forever", 0))
+	mustPut(t, s, testCode("gone", "expired", now-10))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后引用计数按元数据重建，过期记录被清理
+	s = newTestBoltStore(t, path, 0, 0)
+	if st := s.Stats(); st.Entries != 4 || st.Blobs != 3 || st.Pinned != 1 || st.Bytes != int64(len("shared")+len("other")+len("forever")) {
+		t.Fatalf("stats after reload = %+v", st)
+	}
+	if _, ok, _ := s.Get("gone"); ok {
+		t.Error("expired code survived reload")
+	}
+	if err := s.Delete("late"); err != nil {
+		t.Fatal(err)
+	}
+	if code, ok, err := s.Get("early"); !ok || err != nil || code.Content != "shared" {
+		t.Fatalf("shared body lost after delete: %v %v", code, err)
+	}
+	s.Close()
+
+	// 容量缩小时按过期时间淘汰，先过期的先淘汰，置顶记录保留
+	s = newTestBoltStore(t, path, 2, 0)
+	for h, want := range map[string]bool{"early": false, "mid": true, "pinned": true} {
+		if _, ok, _ := s.Get(h); ok != want {
+			t.Errorf("%q kept = %v, want %v", h, ok, want)
+		}
+	}
+}
+
+func TestCodeStoreHistory(t *testing.T) {
+	future := time.Now().Unix() + 3600
+	revised := func() *Code {
+		return &Code{Hash: "a", Content: "v2", DestroyTime: future, Revision: 2, Revisions: []CodeRevision{
+			{Number: 1, Content: "v1"}, {Number: 2, Content: "v2"},
+		}}
+	}
+
+	for name, s := range testStores(t, 0, 0) {
+		t.Run(name, func(t *testing.T) {
+			mustPut(t, s, revised())
+
+			// Get 只读取当前版本的正文
+			code, ok, err := s.Get("a")
+			if !ok || err != nil || code.Content != "v2" {
+				t.Fatalf("get = %v %v %v", code, ok, err)
+			}
+			for _, r := range code.Revisions {
+				if r.Content != "" {
+					t.Errorf("revision %d loaded by Get", r.Number)
+				}
+			}
+
+			// 只有摘要的历史版本写回后正文不丢失
+			code.Comments = []CodeComment{{ID: 1, Author: "bob", Body: "hi"}}
+			mustPut(t, s, code)
+			full, ok, err := s.GetHistory("a")
+			if !ok || err != nil {
+				t.Fatalf("get history = %v %v", ok, err)
+			}
+			if got := []string{full.Revisions[0].Content, full.Revisions[1].Content}; got[0] != "v1" || got[1] != "v2" {
+				t.Errorf("history = %q, want [v1 v2]", got)
+			}
+			if st := s.Stats(); st.Blobs != 2 || st.Bytes != int64(len("v1")+len("v2")+len("bob")+len("hi")) {
+				t.Errorf("stats after rewrite = %+v", st)
+			}
+
+			// 正文已被释放时，只有摘要的记录不能写入
+			if err := s.Delete("a"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Put(code); !errors.Is(err, ErrCodeBodyMissing) {
+				t.Errorf("put without bodies: err = %v, want %v", err, ErrCodeBodyMissing)
+			}
+		})
+	}
+}
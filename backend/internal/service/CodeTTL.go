@@ -0,0 +1,186 @@
+// CodeShare 有效期策略：上下限与默认值、预设选项、延期，以及永不过期的置顶片段
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultCodeTTL = 3600
+	MinCodeTTL     = 60
+	MaxCodeTTL     = 30 * 24 * 3600
+
+	// TTLNever 表示永不过期（置顶），此时 DestroyTime 记为 0，且不参与 LRU 淘汰
+	TTLNever int64 = -1
+)
+
+var (
+	ErrCodeTTLRange  = errors.New("ttl out of range")
+	ErrCodeTTLPreset = errors.New("unknown expiration preset")
+	ErrCodePinLimit  = errors.New("too many pinned codes")
+	ErrCodePinDenied = errors.New("pinning is disabled or requires the admin token")
+)
+
+// TTLPreset 为前端可选的有效期
+type TTLPreset struct {
+	Name string `json:"name"`
+	// TTL 为秒数，永不过期时为 TTLNever
+	TTL int64 `json:"ttl"`
+}
+
+var ttlPresets = []TTLPreset{
+	{"10m", 10 * 60},
+	{"1h", 3600},
+	{"1d", 24 * 3600},
+	{"1w", 7 * 24 * 3600},
+}
+
+// neverPreset 只在允许匿名置顶时出现在预设列表中
+var neverPreset = TTLPreset{"never", TTLNever}
+
+// TTLPolicy 为有效期的上下限与默认值（秒）
+type TTLPolicy struct {
+	Min     int64 `json:"min"`
+	Max     int64 `json:"max"`
+	Default int64 `json:"default"`
+	// MaxPinned 为置顶数量上限，0 或负数表示禁止置顶（默认）
+	MaxPinned int `json:"max_pinned"`
+	// AdminToken 非空时置顶还需携带该 token
+	AdminToken string `json:"-"`
+}
+
+func (p *TTLPolicy) normalize() error {
+	if p.Min <= 0 {
+		p.Min = MinCodeTTL
+	}
+	if p.Max <= 0 {
+		p.Max = MaxCodeTTL
+	}
+	if p.Default <= 0 {
+		p.Default = DefaultCodeTTL
+	}
+	if p.MaxPinned == 0 {
+		p.MaxPinned = -1
+	}
+	if p.Min > p.Max || p.Default < p.Min || p.Default > p.Max {
+		return fmt.Errorf("invalid ttl policy: min %d, max %d, default %d", p.Min, p.Max, p.Default)
+	}
+	return nil
+}
+
+// Presets 返回在策略范围内可用的预设；只有无需 admin token 即可置顶时才提供 never
+func (p TTLPolicy) Presets() []TTLPreset {
+	var out []TTLPreset
+	for _, pr := range ttlPresets {
+		if pr.TTL >= p.Min && pr.TTL <= p.Max {
+			out = append(out, pr)
+		}
+	}
+	if p.MaxPinned > 0 && p.AdminToken == "" {
+		out = append(out, neverPreset)
+	}
+	return out
+}
+
+// canPin 判断是否允许置顶：需开启置顶，配置了 admin token 时还需匹配
+func (p TTLPolicy) canPin(adminToken string) bool {
+	if p.MaxPinned <= 0 {
+		return false
+	}
+	return p.AdminToken == "" ||
+		subtle.ConstantTimeCompare([]byte(hashToken(p.AdminToken)), []byte(hashToken(adminToken))) == 1
+}
+
+// ParseTTLPreset 将预设名转换为 TTL；never 是否允许由置顶检查决定
+func ParseTTLPreset(name string) (int64, error) {
+	if name == neverPreset.Name {
+		return neverPreset.TTL, nil
+	}
+	for _, pr := range ttlPresets {
+		if pr.Name == name {
+			return pr.TTL, nil
+		}
+	}
+	return 0, fmt.Errorf("%w %q", ErrCodeTTLPreset, name)
+}
+
+// Pinned 表示片段永不过期
+func (c *Code) Pinned() bool {
+	return c.DestroyTime == 0
+}
+
+// Expired 判断片段在 now 时是否已过期
+func (c *Code) Expired(now int64) bool {
+	return !c.Pinned() && c.DestroyTime < now
+}
+
+// destroyTime 按策略计算过期时间：0 取默认值，TTLNever 返回 0，需有置顶权限
+func (cs *CodeShare) destroyTime(ttl, now int64, adminToken string) (int64, error) {
+	switch {
+	case ttl == 0:
+		ttl = cs.ttl.Default
+	case ttl == TTLNever:
+		return 0, cs.checkPin(adminToken)
+	case ttl < 0:
+		return 0, ErrCodeInvalidTTL
+	}
+	if ttl < cs.ttl.Min || ttl > cs.ttl.Max {
+		return 0, fmt.Errorf("%w: allowed %ds to %ds", ErrCodeTTLRange, cs.ttl.Min, cs.ttl.Max)
+	}
+	return now + ttl, nil
+}
+
+// checkPin 检查置顶权限与数量上限；并发置顶时可能略微超出
+func (cs *CodeShare) checkPin(adminToken string) error {
+	if !cs.ttl.canPin(adminToken) {
+		return ErrCodePinDenied
+	}
+	if cs.store.Stats().Pinned >= cs.ttl.MaxPinned {
+		return ErrCodePinLimit
+	}
+	return nil
+}
+
+// Extend 由作者延长有效期：在剩余时间上增加 ttl（不超过上限），TTLNever 表示置顶，需有置顶权限；
+// 已置顶的片段保持不变
+func (cs *CodeShare) Extend(hash, token string, ttl int64, adminToken string) (*Code, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	code, err := cs.owned(hash, token)
+	if err != nil {
+		return nil, err
+	}
+	if code.Pinned() {
+		return code, nil
+	}
+
+	now := time.Now().Unix()
+	if ttl == TTLNever {
+		if err := cs.checkPin(adminToken); err != nil {
+			return nil, err
+		}
+		code.DestroyTime = 0
+	} else {
+		if ttl == 0 {
+			ttl = cs.ttl.Default
+		}
+		if ttl < 0 {
+			return nil, ErrCodeInvalidTTL
+		}
+		code.DestroyTime = min(max(code.DestroyTime, now)+ttl, now+cs.ttl.Max)
+	}
+
+	if err := cs.store.Put(code); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// TTLPolicy 返回当前的有效期策略
+func (cs *CodeShare) TTLPolicy() TTLPolicy {
+	return cs.ttl
+}
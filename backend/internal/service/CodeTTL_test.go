@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestPinPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     TTLPolicy
+		adminToken string
+		pinned     int // 已有的置顶数量
+		wantNever  bool
+		wantErr    error
+	}{
+		{name: "disabled by default", wantErr: ErrCodePinDenied},
+		{name: "negative disables", policy: TTLPolicy{MaxPinned: -1}, wantErr: ErrCodePinDenied},
+		{name: "enabled without admin token", policy: TTLPolicy{MaxPinned: 2}, wantNever: true},
+		{name: "enabled at limit", policy: TTLPolicy{MaxPinned: 2}, pinned: 2, wantNever: true, wantErr: ErrCodePinLimit},
+		{name: "admin token missing", policy: TTLPolicy{MaxPinned: 2, AdminToken: "secret"}, wantErr: ErrCodePinDenied},
+		{name: "admin token wrong", policy: TTLPolicy{MaxPinned: 2, AdminToken: "secret"}, adminToken: "guess", wantErr: ErrCodePinDenied},
+		{name: "admin token matches", policy: TTLPolicy{MaxPinned: 2, AdminToken: "secret"}, adminToken: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, err := NewCodeShareService(CodeShareConfig{TTL: tt.policy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.pinned; i++ {
+				mustPut(t, cs.store, testCode(string(rune('a'+i)), "pinned", 0))
+			}
+
+			presets := cs.TTLPolicy().Presets()
+			if got := slices.Contains(presets, neverPreset); got != tt.wantNever {
+				t.Errorf("presets = %v, want never %v", presets, tt.wantNever)
+			}
+
+			res, err := cs.Upload(&CodeUpload{Author: "a", Language: "go", Content: "package main", TTL: TTLNever, AdminToken: tt.adminToken})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !res.Code.Pinned() {
+				t.Error("upload not pinned")
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Go 片段格式化与检查：go/format + go/parser + go/types，不依赖外部工具
+package service
+
+import (
+	"errors"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	GoDiagSyntax = "syntax"
+	GoDiagType   = "type"
+)
+
+// 缺少 package 声明的片段会补上这一行再检查，行号随之回退
+const goSnippetHeader = "package main\n"
+
+type GoDiagnostic struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+	Kind    string `json:"kind"`
+}
+
+type GoCheckResult struct {
+	File string `json:"file,omitempty"`
+	// Formatted 为 gofmt 之后的源码，存在语法错误时为空
+	Formatted   string         `json:"formatted,omitempty"`
+	Changed     bool           `json:"changed"`
+	Errors      []GoDiagnostic `json:"errors"`
+	TypeChecked bool           `json:"type_checked"`
+	// Note 说明类型检查未完整进行的原因（如标准库以外的 import 无法解析）
+	Note string `json:"note,omitempty"`
+}
+
+// IsGo 判断语言名是否为 Go
+func IsGo(lang string) bool {
+	lang = strings.ToLower(lang)
+	return lang == "go" || lang == "golang"
+}
+
+// CheckGo 格式化并检查 Go 源码
+func CheckGo(src string, typeCheck bool) *GoCheckResult {
+	res := &GoCheckResult{Errors: []GoDiagnostic{}}
+
+	fset := token.NewFileSet()
+	code, offset := src, 0
+	file, err := parser.ParseFile(fset, "snippet.go", code, parser.AllErrors|parser.ParseComments)
+	if err != nil && missingPackage(err) {
+		code, offset = goSnippetHeader+src, 1
+		fset = token.NewFileSet()
+		file, err = parser.ParseFile(fset, "snippet.go", code, parser.AllErrors|parser.ParseComments)
+	}
+
+	if err != nil {
+		var list scanner.ErrorList
+		if errors.As(err, &list) {
+			for _, e := range list {
+				res.Errors = append(res.Errors, GoDiagnostic{
+					Line:    e.Pos.Line - offset,
+					Column:  e.Pos.Column,
+					Message: e.Msg,
+					Kind:    GoDiagSyntax,
+				})
+			}
+		} else {
+			res.Errors = append(res.Errors, GoDiagnostic{Message: err.Error(), Kind: GoDiagSyntax})
+		}
+		return res
+	}
+
+	if formatted, err := format.Source([]byte(code)); err == nil {
+		out := string(formatted)
+		if offset > 0 {
+			out = strings.TrimLeft(strings.TrimPrefix(out, goSnippetHeader), "\n")
+		}
+		res.Formatted = out
+		res.Changed = out != src
+	}
+
+	if typeCheck {
+		checkTypes(fset, file, offset, res)
+		sort.SliceStable(res.Errors, func(i, j int) bool {
+			a, b := res.Errors[i], res.Errors[j]
+			return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+		})
+	}
+	return res
+}
+
+func missingPackage(err error) bool {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		return false
+	}
+	return strings.HasPrefix(list[0].Msg, "expected 'package'")
+}
+
+// 类型检查共用一个 goSrcImporter，已导入的标准库包缓存在进程内。
+// 运行环境没有 GOROOT/src 时 import 都无法解析，结果只是部分检查并在 Note 中说明。
+// importer 非并发安全，同一时间只进行一次类型检查；每次检查有时间与导入数量预算，
+// 等待或导入超出预算时退回只做语法检查，不会因为一个大的 import 图阻塞其他请求
+var (
+	goCheckSem      = make(chan struct{}, 1)
+	goCheckImporter *goSrcImporter
+
+	goCheckTimeout    = 2 * time.Second
+	goCheckMaxImports = 200
+)
+
+func checkTypes(fset *token.FileSet, file *ast.File, offset int, res *GoCheckResult) {
+	deadline := time.Now().Add(goCheckTimeout)
+	timer := time.NewTimer(goCheckTimeout)
+	defer timer.Stop()
+	select {
+	case goCheckSem <- struct{}{}:
+		defer func() { <-goCheckSem }()
+	case <-timer.C:
+		res.Note = "type check skipped: checker busy, only syntax was checked"
+		return
+	}
+	if goCheckImporter == nil {
+		goCheckImporter = newGoSrcImporter()
+	}
+	goCheckImporter.begin(deadline, goCheckMaxImports)
+
+	var typeErrors []GoDiagnostic
+	var unresolved []string
+	conf := types.Config{
+		Importer: stdImporter{goCheckImporter},
+		Error: func(err error) {
+			te, ok := err.(types.Error)
+			if !ok {
+				return
+			}
+			// import 失败时 go/types 会用占位包继续检查，不再报告后续相关错误
+			if strings.Contains(te.Msg, "could not import") {
+				unresolved = append(unresolved, te.Msg)
+				return
+			}
+			pos := fset.Position(te.Pos)
+			typeErrors = append(typeErrors, GoDiagnostic{
+				Line:    pos.Line - offset,
+				Column:  pos.Column,
+				Message: te.Msg,
+				Kind:    GoDiagType,
+			})
+		},
+	}
+	_, _ = conf.Check(file.Name.Name, fset, []*ast.File{file}, nil)
+
+	// 预算耗尽时已完成的包仍在缓存中，之后的检查可以继续
+	if goCheckImporter.exceeded {
+		res.Note = "type check skipped: importing the standard library took too long, only syntax was checked"
+		return
+	}
+	res.Errors = append(res.Errors, typeErrors...)
+	res.TypeChecked = true
+	if len(unresolved) > 0 {
+		res.Note = "type check is partial: " + strings.Join(unresolved, "; ")
+	}
+}
+
+// formatGoSources 对 Go 内容执行检查，格式化成功时就地替换为格式化后的源码
+func formatGoSources(content *string, lang string, files []CodeFile) []*GoCheckResult {
+	var results []*GoCheckResult
+	if len(files) == 0 {
+		if !IsGo(lang) {
+			return nil
+		}
+		r := CheckGo(*content, true)
+		if r.Formatted != "" {
+			*content = r.Formatted
+		}
+		return append(results, r)
+	}
+
+	for i := range files {
+		if !IsGo(files[i].Language) {
+			continue
+		}
+		r := CheckGo(files[i].Content, true)
+		r.File = files[i].Name
+		if r.Formatted != "" {
+			files[i].Content = r.Formatted
+		}
+		results = append(results, r)
+	}
+	return results
+}
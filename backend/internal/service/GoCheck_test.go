@@ -0,0 +1,158 @@
+package service
+
+import (
+	"go/build"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withGoCheckBudget 临时替换类型检查的预算，fresh 为 true 时使用空缓存的 importer
+func withGoCheckBudget(t *testing.T, timeout time.Duration, maxImports int, fresh bool) {
+	t.Helper()
+	oldTimeout, oldMax, oldImp := goCheckTimeout, goCheckMaxImports, goCheckImporter
+	goCheckTimeout, goCheckMaxImports = timeout, maxImports
+	if fresh {
+		goCheckImporter = newGoSrcImporter()
+	}
+	t.Cleanup(func() { goCheckTimeout, goCheckMaxImports, goCheckImporter = oldTimeout, oldMax, oldImp })
+}
+
+func TestCheckGo(t *testing.T) {
+	// 类型检查不能依赖 go 命令，也不能修改 build.Default
+	t.Setenv("PATH", "")
+	cgo := build.Default.CgoEnabled
+	// 冷缓存导入 net/http 较慢，这里只验证检查结果
+	withGoCheckBudget(t, time.Minute, 1000, false)
+
+	tests := []struct {
+		name    string
+		src     string
+		errors  []GoDiagnostic
+		partial bool
+	}{
+		{
+			name: "stdlib imports",
+			src: `package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func main() {
+	fmt.Println(strings.ToUpper("ok"), http.StatusOK)
+}
+`,
+		},
+		{
+			name: "type error",
+			src: `package main
+
+import "strconv"
+
+func main() {
+	var n int = strconv.Itoa(1)
+	_ = n
+}
+`,
+			errors: []GoDiagnostic{{Line: 6, Column: 14, Kind: GoDiagType}},
+		},
+		{
+			name: "snippet without package keeps line numbers",
+			src: `import "strings"
+
+func f() int { return strings.Index("a", 1) }
+`,
+			errors: []GoDiagnostic{{Line: 3, Column: 42, Kind: GoDiagType}},
+		},
+		{
+			name: "third-party import is not resolved",
+			src: `package main
+
+import "github.com/example/lib"
+
+func main() { lib.Run() }
+`,
+			partial: true,
+		},
+		{
+			name: "syntax error",
+			src: `package main
+
+func main() { println(1 2) }
+`,
+			errors: []GoDiagnostic{{Line: 3, Column: 25, Kind: GoDiagSyntax}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := CheckGo(tt.src, true)
+			if len(res.Errors) != len(tt.errors) {
+				t.Fatalf("errors = %+v, want %d", res.Errors, len(tt.errors))
+			}
+			for i, want := range tt.errors {
+				got := res.Errors[i]
+				if got.Line != want.Line || got.Column != want.Column || got.Kind != want.Kind {
+					t.Errorf("error %d = %+v, want line %d col %d %s", i, got, want.Line, want.Column, want.Kind)
+				}
+			}
+			if partial := strings.HasPrefix(res.Note, "type check is partial"); partial != tt.partial {
+				t.Errorf("note = %q, want partial %v", res.Note, tt.partial)
+			}
+			if len(tt.errors) == 0 && !res.TypeChecked {
+				t.Error("type check skipped")
+			}
+		})
+	}
+	if build.Default.CgoEnabled != cgo {
+		t.Error("build.Default was modified")
+	}
+}
+
+func TestCheckGoBudget(t *testing.T) {
+	t.Setenv("PATH", "")
+	src := `package main
+
+import "encoding/json"
+
+func main() { var s string = json.Valid(nil); _ = s }
+`
+	tests := []struct {
+		name       string
+		timeout    time.Duration
+		maxImports int
+		busy       bool
+		checked    bool
+		note       string
+	}{
+		{name: "import budget exceeded", timeout: time.Minute, maxImports: 5, note: "type check skipped"},
+		{name: "checker busy", timeout: 10 * time.Millisecond, maxImports: 1000, busy: true, note: "type check skipped: checker busy"},
+		// 之前超出预算时已完成的包仍在缓存中
+		{name: "continues from cache", timeout: time.Minute, maxImports: 1000, checked: true},
+	}
+
+	withGoCheckBudget(t, 0, 0, true)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goCheckTimeout, goCheckMaxImports = tt.timeout, tt.maxImports
+			if tt.busy {
+				goCheckSem <- struct{}{}
+				defer func() { <-goCheckSem }()
+			}
+			res := CheckGo(src, true)
+			if res.TypeChecked != tt.checked {
+				t.Fatalf("type checked = %v, want %v (note %q)", res.TypeChecked, tt.checked, res.Note)
+			}
+			if !strings.HasPrefix(res.Note, tt.note) {
+				t.Errorf("note = %q, want prefix %q", res.Note, tt.note)
+			}
+			// 退回语法检查时不报告类型错误
+			if want := map[bool]int{true: 1, false: 0}[tt.checked]; len(res.Errors) != want {
+				t.Errorf("errors = %+v, want %d", res.Errors, want)
+			}
+		})
+	}
+}
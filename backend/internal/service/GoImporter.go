@@ -0,0 +1,128 @@
+// Go 片段类型检查用的标准库导入：直接从 GOROOT 源码解析并缓存，不调用 go 命令
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var errGoImportBudget = errors.New("import budget exceeded")
+
+// goSrcImporter 从 GOROOT/src 导入标准库，使用私有的 build.Context，不修改 build.Default；
+// 非并发安全，调用方需串行使用。已导入的包缓存在进程内，超出预算时未完成的包不缓存
+type goSrcImporter struct {
+	ctxt    build.Context
+	fset    *token.FileSet
+	sizes   types.Sizes
+	pkgs    map[string]*types.Package
+	loading map[string]bool
+
+	// 单次检查的预算，由 begin 设置
+	deadline time.Time
+	left     int
+	exceeded bool
+}
+
+func newGoSrcImporter() *goSrcImporter {
+	ctxt := build.Default
+	// 关闭 cgo，让 net 等包选用纯 Go 实现
+	ctxt.CgoEnabled = false
+	ctxt.GOPATH = ""
+	// 自定义 IsDir 后 go/build 不会调用 go 命令（见 build.Context.importGo）
+	ctxt.IsDir = func(path string) bool {
+		fi, err := os.Stat(path)
+		return err == nil && fi.IsDir()
+	}
+	return &goSrcImporter{
+		ctxt:    ctxt,
+		fset:    token.NewFileSet(),
+		sizes:   types.SizesFor("gc", ctxt.GOARCH),
+		pkgs:    make(map[string]*types.Package),
+		loading: make(map[string]bool),
+	}
+}
+
+// begin 开始一次检查：最多新导入 maxImports 个包，且不晚于 deadline
+func (imp *goSrcImporter) begin(deadline time.Time, maxImports int) {
+	imp.deadline, imp.left, imp.exceeded = deadline, maxImports, false
+}
+
+func (imp *goSrcImporter) Import(path string) (*types.Package, error) {
+	return imp.ImportFrom(path, "", 0)
+}
+
+// ImportFrom 在标准库内部递归导入时使用，srcDir 用于解析 GOROOT/src/vendor
+func (imp *goSrcImporter) ImportFrom(path, srcDir string, _ types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	bp, err := imp.ctxt.Import(path, srcDir, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !bp.Goroot {
+		return nil, fmt.Errorf("%s is not in the standard library", path)
+	}
+	if pkg, ok := imp.pkgs[bp.ImportPath]; ok {
+		return pkg, nil
+	}
+	if imp.loading[bp.ImportPath] {
+		return nil, fmt.Errorf("import cycle via %s", bp.ImportPath)
+	}
+	if imp.left <= 0 || time.Now().After(imp.deadline) {
+		imp.exceeded = true
+		return nil, errGoImportBudget
+	}
+	imp.left--
+
+	imp.loading[bp.ImportPath] = true
+	defer delete(imp.loading, bp.ImportPath)
+
+	files := make([]*ast.File, 0, len(bp.GoFiles))
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(imp.fset, filepath.Join(bp.Dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	var hardErr error
+	conf := types.Config{
+		Importer:         imp,
+		IgnoreFuncBodies: true,
+		Sizes:            imp.sizes,
+		Error: func(err error) {
+			if te, ok := err.(types.Error); hardErr == nil && (!ok || !te.Soft) {
+				hardErr = err
+			}
+		},
+	}
+	pkg, _ := conf.Check(bp.ImportPath, imp.fset, files, nil)
+	if hardErr != nil {
+		return nil, fmt.Errorf("type-checking package %q failed: %w", bp.ImportPath, hardErr)
+	}
+	imp.pkgs[bp.ImportPath] = pkg
+	return pkg, nil
+}
+
+// stdImporter 供片段本身使用：只接受标准库路径，第三方包与 cgo 直接报错，避免读取任意路径
+type stdImporter struct {
+	imp *goSrcImporter
+}
+
+func (s stdImporter) Import(path string) (*types.Package, error) {
+	if path == "C" || strings.Contains(strings.Split(path, "/")[0], ".") {
+		return nil, fmt.Errorf("%s is not in the standard library", path)
+	}
+	return s.imp.ImportFrom(path, "", 0)
+}
@@ -0,0 +1,366 @@
+// 代码语言自动识别：文件名 > shebang > modeline > 关键字统计
+package service
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	LanguageAuto      = "auto"
+	LanguagePlaintext = "plaintext"
+)
+
+// LanguageDetection 为一次识别的结果；File 为多文件片段中的文件名
+type LanguageDetection struct {
+	File       string  `json:"file,omitempty"`
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+	// Source 为识别依据：filename / shebang / modeline / content
+	Source string `json:"source"`
+}
+
+// 扩展名到语言，语言名与前端 / codeFileExts 保持一致
+var langByExt = map[string]string{
+	".go":   "go",
+	".js":   "javascript",
+	".mjs":  "javascript",
+	".cjs":  "javascript",
+	".jsx":  "javascript",
+	".ts":   "typescript",
+	".tsx":  "typescript",
+	".vue":  "vue",
+	".py":   "python",
+	".c":    "c_cpp",
+	".h":    "c_cpp",
+	".cc":   "c_cpp",
+	".cpp":  "c_cpp",
+	".hpp":  "c_cpp",
+	".java": "java",
+	".rs":   "rust",
+	".sh":   "shell",
+	".bash": "shell",
+	".zsh":  "shell",
+	".sql":  "sql",
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".html": "html",
+	".htm":  "html",
+	".css":  "css",
+	".md":   "markdown",
+	".txt":  LanguagePlaintext,
+}
+
+var langByName = map[string]string{
+	"dockerfile": "dockerfile",
+	"makefile":   "makefile",
+	"go.mod":     "go",
+}
+
+// shebang 中的解释器
+var langByInterpreter = map[string]string{
+	"python":  "python",
+	"python3": "python",
+	"python2": "python",
+	"node":    "javascript",
+	"deno":    "typescript",
+	"ts-node": "typescript",
+	"sh":      "shell",
+	"bash":    "shell",
+	"zsh":     "shell",
+}
+
+// modeline 中的名称（vim ft / emacs mode）
+var langByModeline = map[string]string{
+	"go":         "go",
+	"python":     "python",
+	"javascript": "javascript",
+	"js":         "javascript",
+	"typescript": "typescript",
+	"c":          "c_cpp",
+	"cpp":        "c_cpp",
+	"c++":        "c_cpp",
+	"java":       "java",
+	"rust":       "rust",
+	"sh":         "shell",
+	"bash":       "shell",
+	"shell":      "shell",
+	"sql":        "sql",
+	"yaml":       "yaml",
+	"json":       "json",
+	"html":       "html",
+	"css":        "css",
+	"markdown":   "markdown",
+}
+
+var (
+	vimModeline   = regexp.MustCompile(`(?m)\b(?:vim?|ex):.*?\b(?:ft|filetype|syntax)=([\w+]+)`)
+	emacsModeline = regexp.MustCompile(`-\*-.*?\bmode:\s*([\w+]+).*?-\*-|-\*-\s*([\w+]+)\s*-\*-`)
+)
+
+type langPattern struct {
+	re     *regexp.Regexp
+	weight float64
+}
+
+func patterns(weighted map[string]float64) []langPattern {
+	ps := make([]langPattern, 0, len(weighted))
+	for expr, w := range weighted {
+		ps = append(ps, langPattern{re: regexp.MustCompile(expr), weight: w})
+	}
+	return ps
+}
+
+var jsPatterns = map[string]float64{
+	`\b(?:const|let|var) \w+ = `: 1,
+	`\bfunction\s*\w*\s*\(`:      2,
+	`=>`:                         1,
+	`\bconsole\.log\(`:           2,
+	`\brequire\(`:                2,
+	`\bmodule\.exports\b`:        3,
+	`\b(?:document|window)\.`:    2,
+	`\bexport default\b`:         1,
+}
+
+// TypeScript 只统计类型特征，命中时在 JS 得分上累加
+var tsExtraPatterns = map[string]float64{
+	`:\s*(?:string|number|boolean|any|void|unknown)\b`: 3,
+	`\binterface \w+`:                  2,
+	`\b(?:export )?type \w+\s*=`:       2,
+	`\b(?:public|private|readonly) \w`: 1,
+	`\bas const\b`:                     2,
+}
+
+// 按顺序比较，得分相同时靠前的语言优先
+var langPatterns = []struct {
+	lang string
+	// base 非空时，本语言得分 = base 得分 + 自身特征得分（自身特征未命中则为 0）
+	base     string
+	patterns []langPattern
+}{
+	{"go", "", patterns(map[string]float64{
+		`(?m)^package \w+\s*$`:                 4,
+		`\bfunc\s+(?:\(\w+ \*?\w+\)\s*)?\w+\(`: 3,
+		`:=`:                                   1,
+		`(?m)^import \(`:                       3,
+		`\bfmt\.\w+\(`:                         2,
+		`\berr != nil\b`:                       3,
+		`\bgo func\b|\bchan\b|\bdefer\b`:       2,
+	})},
+	{"python", "", patterns(map[string]float64{
+		`(?m)^\s*def \w+\(.*\):`:                     3,
+		`(?m)^(?:from [\w.]+ import|import [\w.]+$)`: 2,
+		`\bself\b`:                      1,
+		`(?m)^\s*class \w+(?:\(.*\))?:`: 2,
+		`\belif\b`:                      2,
+		`\b(?:None|True|False)\b`:       1,
+		`__name__|__init__`:             3,
+	})},
+	{"javascript", "", patterns(jsPatterns)},
+	{"typescript", "javascript", patterns(tsExtraPatterns)},
+	{"vue", "", patterns(map[string]float64{
+		`(?m)^<template>`:                    5,
+		`<script(?: setup)?(?: lang="ts")?>`: 3,
+		`(?m)^<style( scoped)?>`:             2,
+		`\bv-(?:if|for|model|bind|on)\b`:     2,
+	})},
+	{"c_cpp", "", patterns(map[string]float64{
+		`(?m)^#include\s*[<"]`:          4,
+		`\bint main\s*\(`:               3,
+		`\bstd::`:                       3,
+		`\bprintf\(`:                    1,
+		`\b(?:nullptr|malloc|sizeof)\b`: 2,
+	})},
+	{"java", "", patterns(map[string]float64{
+		`\bpublic (?:static )?(?:final )?(?:class|void|interface)\b`: 3,
+		`\bSystem\.out\.print`: 4,
+		`(?m)^import java\.`:   4,
+		`@Override\b`:          3,
+		`(?m)^package [\w.]+;`: 3,
+	})},
+	{"rust", "", patterns(map[string]float64{
+		`\bfn \w+\s*[<(]`: 3,
+		`\blet mut\b`:     3,
+		`\bprintln!\(`:    4,
+		`\bimpl\b`:        2,
+		`(?m)^use \w+::`:  3,
+		`&(?:mut )?str\b`: 2,
+	})},
+	{"shell", "", patterns(map[string]float64{
+		`(?m)^\s*echo `:                           2,
+		`\$\{\w+\}|\$\w+`:                         1,
+		`(?m)^\s*(?:fi|done|esac|then)\b`:         2,
+		`(?m)^\s*export \w+=`:                     2,
+		`(?m)^\s*(?:sudo |apt|yum|brew |cd |ls )`: 1,
+	})},
+	{"sql", "", patterns(map[string]float64{
+		`(?i)\bselect\b[\s\S]+?\bfrom\b`:               4,
+		`(?i)\binsert\s+into\b`:                        4,
+		`(?i)\bcreate\s+(?:table|index|database)\b`:    4,
+		`(?i)\bwhere\b`:                                1,
+		`(?i)\b(?:update\s+\w+\s+set|delete\s+from)\b`: 3,
+	})},
+	{"html", "", patterns(map[string]float64{
+		`(?i)<!doctype html`:                             5,
+		`<(?:div|span|html|body|head|p|a|ul|li)\b[^>]*>`: 2,
+		`</\w+>`: 1,
+	})},
+	{"css", "", patterns(map[string]float64{
+		`(?m)^\s*[.#]?[\w-]+(?:\s*[,>+~]?\s*[.#]?[\w-]+)*\s*\{`:          1,
+		`(?m)^\s*(?:color|margin|padding|display|font-size|background):`: 3,
+		`@media\b`: 3,
+	})},
+	{"yaml", "", patterns(map[string]float64{
+		`(?m)^[\w-]+:\s`:   1,
+		`(?m)^\s*- [\w"']`: 1,
+		`(?m)^---\s*$`:     2,
+	})},
+	{"markdown", "", patterns(map[string]float64{
+		`(?m)^#{1,6} \S`:      2,
+		"(?m)^```":            3,
+		`\[[^\]]+\]\([^)]+\)`: 2,
+		`(?m)^\s*[-*] \S`:     1,
+	})},
+}
+
+// DetectLanguage 根据文件名和内容识别语言，confidence 范围为 [0, 1]
+func DetectLanguage(content, filename string) LanguageDetection {
+	if filename != "" {
+		base := strings.ToLower(path.Base(filename))
+		if lang, ok := langByName[base]; ok {
+			return LanguageDetection{Language: lang, Confidence: 0.95, Source: "filename"}
+		}
+		if lang, ok := langByExt[path.Ext(base)]; ok {
+			return LanguageDetection{Language: lang, Confidence: 0.95, Source: "filename"}
+		}
+	}
+
+	if lang := detectShebang(content); lang != "" {
+		return LanguageDetection{Language: lang, Confidence: 0.95, Source: "shebang"}
+	}
+	if lang := detectModeline(content); lang != "" {
+		return LanguageDetection{Language: lang, Confidence: 0.9, Source: "modeline"}
+	}
+
+	trimmed := strings.TrimSpace(content)
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return LanguageDetection{Language: "json", Confidence: 0.95, Source: "content"}
+	}
+
+	return detectByContent(content)
+}
+
+func detectShebang(content string) string {
+	if !strings.HasPrefix(content, "#!") {
+		return ""
+	}
+	line, _, _ := strings.Cut(content, "\n")
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := path.Base(fields[0])
+	if interp == "env" {
+		// #!/usr/bin/env -S node --flag
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				interp = f
+				break
+			}
+		}
+	}
+	return langByInterpreter[interp]
+}
+
+// 只在开头和结尾几行查找 modeline
+func detectModeline(content string) string {
+	lines := strings.Split(content, "\n")
+	if len(lines) > 10 {
+		lines = append(lines[:5], lines[len(lines)-5:]...)
+	}
+	for _, line := range lines {
+		if m := vimModeline.FindStringSubmatch(line); m != nil {
+			if lang, ok := langByModeline[strings.ToLower(m[1])]; ok {
+				return lang
+			}
+		}
+		if m := emacsModeline.FindStringSubmatch(line); m != nil {
+			name := m[1]
+			if name == "" {
+				name = m[2]
+			}
+			if lang, ok := langByModeline[strings.ToLower(name)]; ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// detectByContent 统计各语言特征出现次数并加权打分
+func detectByContent(content string) LanguageDetection {
+	const maxHits = 3
+
+	best, second := 0.0, 0.0
+	bestLang := LanguagePlaintext
+	scores := make(map[string]float64, len(langPatterns))
+	for _, lp := range langPatterns {
+		score := 0.0
+		for _, p := range lp.patterns {
+			hits := len(p.re.FindAllStringIndex(content, maxHits))
+			score += p.weight * float64(hits)
+		}
+		if lp.base != "" && score > 0 {
+			score += scores[lp.base]
+		}
+		scores[lp.lang] = score
+
+		switch {
+		case score > best:
+			second = best
+			best, bestLang = score, lp.lang
+		case score > second:
+			second = score
+		}
+	}
+
+	if best < 3 {
+		return LanguageDetection{Language: LanguagePlaintext, Confidence: 0, Source: "content"}
+	}
+
+	// 领先幅度 × 特征数量，上限 0.9，低于文件名等强信号
+	confidence := best / (best + second) * min(1, best/12)
+	confidence = min(0.9, float64(int(confidence*100))/100)
+	return LanguageDetection{Language: bestLang, Confidence: confidence, Source: "content"}
+}
+
+// resolveLanguages 对缺省或 auto 的语言做自动识别，返回识别结果；加密内容无法识别
+func resolveLanguages(lang *string, content, filename string, files []CodeFile, encrypted bool) []LanguageDetection {
+	var detected []LanguageDetection
+	if len(files) > 0 {
+		for i := range files {
+			if files[i].Language != "" && files[i].Language != LanguageAuto {
+				continue
+			}
+			d := DetectLanguage(files[i].Content, files[i].Name)
+			d.File = files[i].Name
+			files[i].Language = d.Language
+			detected = append(detected, d)
+		}
+		return detected
+	}
+
+	if *lang != "" && *lang != LanguageAuto {
+		return nil
+	}
+	if encrypted {
+		*lang = LanguagePlaintext
+		return nil
+	}
+	d := DetectLanguage(content, filename)
+	*lang = d.Language
+	return []LanguageDetection{d}
+}
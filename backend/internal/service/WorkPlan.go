@@ -1,52 +1,141 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 )
 
+// 每个计划默认最多保存的 TODO 数量
+const DefaultMaxTODOs = 1000
+
+// 优先级，数值越大越紧急
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+const (
+	MaxTODOTags    = 10
+	MaxTODOTagSize = 32
+)
+
+var (
+	ErrPlanNotFound    = errors.New("plan not found")
+	ErrTODONotFound    = errors.New("TODO not exists")
+	ErrTODOEmpty       = errors.New("TODO content is empty")
+	ErrTODOTooMany     = errors.New("TODO is too many")
+	ErrTODOBadPosition = errors.New("invalid position")
+	ErrTODOPriority    = fmt.Errorf("priority must be between %d and %d", PriorityNone, PriorityHigh)
+	ErrTODOTags        = fmt.Errorf("at most %d tags of %d bytes each", MaxTODOTags, MaxTODOTagSize)
+)
+
+type WorkPlanConfig struct {
+	// MaxTODOs 为单个计划的 TODO 数量上限，0 使用默认值，负数表示不限制
+	MaxTODOs int
+}
+
 type WorkPlan struct {
-	mu   sync.RWMutex
-	Plan map[string]*PersonalPlan
+	mu sync.RWMutex
+	// Plan 以编辑 hash 为 key，views 以只读 hash 为 key
+	Plan     map[string]*PersonalPlan
+	views    map[string]*PersonalPlan
+	maxTODOs int
 }
 
 type PersonalPlan struct {
-	mu    sync.RWMutex
-	Hash  string    `json:"hash"`
-	TODOs [200]TODO `json:"todos"`
-	sta   []int     `json:"-"` // 空闲槽位池
+	mu sync.RWMutex
+	// Hash 可以编辑计划，ViewHash 只能查看
+	Hash     string `json:"hash"`
+	ViewHash string `json:"view_hash"`
+	// TODOs 按用户排列的顺序保存
+	TODOs []TODO `json:"todos"`
+	// AutoDone 为 true 时，子任务全部完成会自动完成父任务
+	AutoDone bool `json:"auto_done"`
+	// nextID 单调递增，删除后 ID 不会被复用
+	nextID   int
+	maxTODOs int
+	clients  map[chan PlanEvent]struct{}
+
+	members      []Member
+	lastMemberID int
+
+	activity   []Activity
+	lastSeq    int
+	undo, redo []undoEntry
+
+	sessions      []TimeSession
+	lastSessionID int
 }
 
 type TODO struct {
 	Id      int    `json:"id"`
 	Content string `json:"content"`
 	Done    bool   `json:"done"`
+	// ParentID 为父任务 ID，0 表示顶层任务
+	ParentID int `json:"parent_id,omitempty"`
+	// Due 为截止时间（unix 秒），0 表示没有截止时间
+	Due      int64    `json:"due,omitempty"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags,omitempty"`
+	// Assignee 为负责人的成员 ID，0 表示未分配
+	Assignee  int   `json:"assignee,omitempty"`
+	CreatedAt int64 `json:"created_at"`
+	// CompletedAt 为最近一次完成的时间，未完成时为 0
+	CompletedAt int64 `json:"completed_at,omitempty"`
+	// Repeat 为规范化后的 RRULE，空表示不重复
+	Repeat string `json:"repeat,omitempty"`
+	// SeriesID 为重复系列第一个实例的 ID，同一系列的实例共享
+	SeriesID int `json:"series_id,omitempty"`
 }
 
-func NewWorkPlan() *WorkPlan {
+// TODOFields 为可选字段，nil 表示不设置 / 不修改；Due 为 0 表示清除截止时间，Tags 为空切片表示清空标签
+type TODOFields struct {
+	// ParentID 为 0 表示移到顶层
+	ParentID *int
+	Due      *int64
+	Priority *int
+	Tags     *[]string
+	// Repeat 为简写或 RRULE，空字符串表示取消重复
+	Repeat *string
+	// Assignee 为 0 表示取消分配
+	Assignee *int
+}
+
+func NewWorkPlan(cfg WorkPlanConfig) *WorkPlan {
+	if cfg.MaxTODOs == 0 {
+		cfg.MaxTODOs = DefaultMaxTODOs
+	}
 	return &WorkPlan{
-		Plan: make(map[string]*PersonalPlan),
+		Plan:     make(map[string]*PersonalPlan),
+		views:    make(map[string]*PersonalPlan),
+		maxTODOs: cfg.MaxTODOs,
 	}
 }
 
 func (wp *WorkPlan) NewPersonalPlan() *PersonalPlan {
 	pp := &PersonalPlan{
-		Hash: GetHash(10),
-		sta:  make([]int, 200),
-	}
-
-	// 初始化空闲槽位列表
-	for i := 0; i < 200; i++ {
-		pp.sta[i] = i
+		Hash:     GetHash(10),
+		ViewHash: GetHash(10),
+		TODOs:    []TODO{},
+		nextID:   1,
+		maxTODOs: wp.maxTODOs,
 	}
 
 	wp.mu.Lock()
 	wp.Plan[pp.Hash] = pp
+	wp.views[pp.ViewHash] = pp
 	wp.mu.Unlock()
 
 	return pp
 }
 
+// GetPlan 按编辑 hash 查找计划
 func (wp *WorkPlan) GetPlan(hash string) *PersonalPlan {
 	wp.mu.RLock()
 	defer wp.mu.RUnlock()
@@ -55,91 +144,282 @@ func (wp *WorkPlan) GetPlan(hash string) *PersonalPlan {
 
 // ---------------- TODO 逻辑 ----------------
 
-func (pp *PersonalPlan) AddTODO(content string) error {
+// AddTODO 追加到列表末尾，返回新建的 TODO
+func (pp *PersonalPlan) AddTODO(content string, f TODOFields) (TODO, error) {
+	if content == "" {
+		return TODO{}, ErrTODOEmpty
+	}
+	if err := f.normalize(); err != nil {
+		return TODO{}, err
+	}
+
 	pp.mu.Lock()
 	defer pp.mu.Unlock()
 
-	if len(pp.sta) == 0 {
-		return fmt.Errorf("TODO is too many")
+	if pp.maxTODOs > 0 && len(pp.TODOs) >= pp.maxTODOs {
+		return TODO{}, fmt.Errorf("%w (max %d)", ErrTODOTooMany, pp.maxTODOs)
+	}
+	if f.ParentID != nil {
+		if err := pp.checkParent(0, *f.ParentID); err != nil {
+			return TODO{}, err
+		}
+	}
+	if err := pp.checkAssignee(f); err != nil {
+		return TODO{}, err
+	}
+	before := slices.Clone(pp.TODOs)
+
+	t := TODO{
+		Id:        pp.nextID,
+		Content:   content,
+		Done:      false,
+		CreatedAt: time.Now().Unix(),
 	}
+	f.apply(&t)
+	pp.nextID++
+	pp.TODOs = append(pp.TODOs, t)
+	changed := pp.rollUp(t.ParentID)
+	pp.record(ActivityAdd, before, []int{t.Id}, t.Content)
+	pp.broadcast(PlanEvent{Type: PlanEventAdd, TODOs: append([]TODO{t}, changed...)})
+	return t, nil
+}
 
-	id := pp.sta[len(pp.sta)-1]
-	pp.sta = pp.sta[:len(pp.sta)-1]
+// DeleteTODO 删除 TODO 及其全部子任务
+func (pp *PersonalPlan) DeleteTODO(id int) error {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
 
-	pp.TODOs[id] = TODO{
-		Id:      id,
-		Content: content,
-		Done:    false,
+	i := pp.find(id)
+	if i < 0 {
+		return ErrTODONotFound
 	}
+	parentID, summary := pp.TODOs[i].ParentID, pp.TODOs[i].Content
+	before := slices.Clone(pp.TODOs)
+
+	ids := pp.subtree(id)
+	var deleted []int
+	pp.TODOs = slices.DeleteFunc(pp.TODOs, func(t TODO) bool {
+		if ids[t.Id] {
+			deleted = append(deleted, t.Id)
+		}
+		return ids[t.Id]
+	})
+	changed := pp.rollUp(parentID)
+	pp.record(ActivityDelete, before, deleted, summary)
+	pp.broadcast(PlanEvent{Type: PlanEventDelete, IDs: deleted, TODOs: changed})
 	return nil
 }
 
-func (pp *PersonalPlan) DeleteTODO(id int) error {
-	if id < 0 || id >= 200 {
-		return fmt.Errorf("invalid TODO id")
+// EditTODO 修改内容及可选字段，content 为空时保持原内容
+func (pp *PersonalPlan) EditTODO(id int, content string, f TODOFields) error {
+	if err := f.normalize(); err != nil {
+		return err
 	}
 
 	pp.mu.Lock()
 	defer pp.mu.Unlock()
 
-	// 回收槽位
-	pp.sta = append(pp.sta, id)
+	i := pp.find(id)
+	if i < 0 {
+		return ErrTODONotFound
+	}
+	oldParent := pp.TODOs[i].ParentID
+	if f.ParentID != nil {
+		if err := pp.checkParent(id, *f.ParentID); err != nil {
+			return err
+		}
+	}
+	if err := pp.checkAssignee(f); err != nil {
+		return err
+	}
+	before := slices.Clone(pp.TODOs)
 
-	// 清空内容
-	pp.TODOs[id] = TODO{}
+	if content != "" {
+		pp.TODOs[i].Content = content
+	}
+	f.apply(&pp.TODOs[i])
+	changed := []TODO{pp.TODOs[i]}
+	if newParent := pp.TODOs[i].ParentID; newParent != oldParent {
+		changed = append(changed, pp.rollUp(oldParent)...)
+		changed = append(changed, pp.rollUp(newParent)...)
+	}
+	pp.record(ActivityEdit, before, []int{id}, pp.TODOs[i].Content)
+	pp.broadcast(PlanEvent{Type: PlanEventEdit, TODOs: changed})
 	return nil
 }
 
-func (pp *PersonalPlan) EditTODO(id int, content string) error {
-	if id < 0 || id >= 200 {
-		return fmt.Errorf("invalid TODO id")
-	}
+// DoneResult 为切换完成状态的结果
+type DoneResult struct {
+	// Next 为完成重复任务时插入的下一次实例
+	Next *TODO `json:"next"`
+	// Skipped 为计划已达到 TODO 上限、没有生成下一次实例的重复任务（本身或被自动完成的父任务）
+	Skipped []int `json:"skipped,omitempty"`
+}
 
+// SetTODODone 切换完成状态；完成重复任务时在其后插入下一次实例，
+// 计划已达到 TODO 上限时仍然完成，只是不生成下一次实例
+func (pp *PersonalPlan) SetTODODone(id int) (*DoneResult, error) {
 	pp.mu.Lock()
 	defer pp.mu.Unlock()
 
-	if pp.TODOs[id].Content == "" {
-		return fmt.Errorf("TODO not exists")
+	i := pp.find(id)
+	if i < 0 {
+		return nil, ErrTODONotFound
 	}
+	now := time.Now()
 
-	pp.TODOs[id].Content = content
-	return nil
+	before := slices.Clone(pp.TODOs)
+	t := &pp.TODOs[i]
+	t.Done = !t.Done
+	if t.Done {
+		t.CompletedAt = now.Unix()
+	} else {
+		t.CompletedAt = 0
+	}
+	done := *t
+
+	res := &DoneResult{}
+	if done.Done && done.Repeat != "" {
+		next, skipped := pp.spawnNext(i, now)
+		res.Next = next
+		if skipped {
+			res.Skipped = append(res.Skipped, id)
+		}
+	}
+	changed, skipped := pp.rollUpSkips(done.ParentID)
+	res.Skipped = append(res.Skipped, skipped...)
+
+	op, ids := ActivityDone, []int{id}
+	if !done.Done {
+		op = ActivityReopen
+	}
+	if res.Next != nil {
+		ids = append(ids, res.Next.Id)
+	}
+	pp.record(op, before, ids, done.Content)
+	pp.broadcast(PlanEvent{Type: PlanEventDone, TODOs: append([]TODO{done}, changed...)})
+	if res.Next != nil {
+		pp.broadcast(PlanEvent{Type: PlanEventAdd, TODOs: []TODO{*res.Next}})
+	}
+	return res, nil
 }
 
-func (pp *PersonalPlan) SetTODODone(id int) error {
-	if id < 0 || id >= 200 {
-		return fmt.Errorf("invalid TODO id")
+// MoveTODO 将 TODO 移动到列表中的 position（从 0 开始），超出末尾时放到最后
+func (pp *PersonalPlan) MoveTODO(id, position int) error {
+	if position < 0 {
+		return ErrTODOBadPosition
 	}
 
 	pp.mu.Lock()
 	defer pp.mu.Unlock()
 
-	if pp.TODOs[id].Content == "" {
-		return fmt.Errorf("TODO not exists")
+	i := pp.find(id)
+	if i < 0 {
+		return ErrTODONotFound
 	}
+	position = min(position, len(pp.TODOs)-1)
+	before := slices.Clone(pp.TODOs)
 
-	pp.TODOs[id].Done = !pp.TODOs[id].Done
+	t := pp.TODOs[i]
+	pp.TODOs = append(pp.TODOs[:i], pp.TODOs[i+1:]...)
+	pp.TODOs = append(pp.TODOs[:position], append([]TODO{t}, pp.TODOs[position:]...)...)
+	pp.record(ActivityMove, before, []int{id}, t.Content)
+	pp.broadcast(PlanEvent{Type: PlanEventMove, Order: pp.order()})
 	return nil
 }
 
+// GetTODOs 未完成的在前，已完成的在后，各自保持列表中的顺序
 func (pp *PersonalPlan) GetTODOs() []TODO {
 	pp.mu.RLock()
 	defer pp.mu.RUnlock()
+	return pp.sorted()
+}
 
-	todos := make([]TODO, 0, 200)
+// sorted 返回未完成在前的列表副本；调用方需持有锁
+func (pp *PersonalPlan) sorted() []TODO {
+	todos := make([]TODO, 0, len(pp.TODOs))
 
 	// 未完成的在前
 	for _, t := range pp.TODOs {
-		if t.Content != "" && !t.Done {
+		if !t.Done {
 			todos = append(todos, t)
 		}
 	}
 	// 已完成的在后
 	for _, t := range pp.TODOs {
-		if t.Content != "" && t.Done {
+		if t.Done {
 			todos = append(todos, t)
 		}
 	}
 
 	return todos
 }
+
+// normalize 校验优先级与重复规则，标签去掉首尾空白、转为小写并去重
+func (f *TODOFields) normalize() error {
+	if f.Priority != nil && (*f.Priority < PriorityNone || *f.Priority > PriorityHigh) {
+		return ErrTODOPriority
+	}
+	if f.Repeat != nil {
+		repeat, err := normalizeRepeat(*f.Repeat)
+		if err != nil {
+			return err
+		}
+		f.Repeat = &repeat
+	}
+	if f.Tags == nil {
+		return nil
+	}
+
+	var tags []string
+	for _, tag := range *f.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || slices.Contains(tags, tag) {
+			continue
+		}
+		if len(tag) > MaxTODOTagSize {
+			return ErrTODOTags
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) > MaxTODOTags {
+		return ErrTODOTags
+	}
+	f.Tags = &tags
+	return nil
+}
+
+func (f *TODOFields) apply(t *TODO) {
+	if f.ParentID != nil {
+		t.ParentID = *f.ParentID
+	}
+	if f.Due != nil {
+		t.Due = *f.Due
+	}
+	if f.Priority != nil {
+		t.Priority = *f.Priority
+	}
+	if f.Tags != nil {
+		t.Tags = *f.Tags
+	}
+	if f.Assignee != nil {
+		t.Assignee = *f.Assignee
+	}
+	if f.Repeat != nil {
+		t.Repeat = *f.Repeat
+		// 第一次设置重复规则时以自己作为系列的起点
+		if t.Repeat != "" && t.SeriesID == 0 {
+			t.SeriesID = t.Id
+		}
+	}
+}
+
+// find 返回 id 在列表中的下标，不存在时返回 -1；调用方需持有锁
+func (pp *PersonalPlan) find(id int) int {
+	for i, t := range pp.TODOs {
+		if t.Id == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,149 @@
+// WorkPlan 操作记录：只追加的活动日志，以及基于快照的撤销 / 重做
+package service
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+const (
+	// 每个计划保留的活动条数，超出后丢弃最早的
+	MaxPlanActivity = 1000
+	// 可撤销的操作数
+	MaxPlanUndo = 50
+)
+
+// 活动类型
+const (
+	ActivityAdd      = "add"
+	ActivityEdit     = "edit"
+	ActivityDelete   = "delete"
+	ActivityDone     = "done"
+	ActivityReopen   = "reopen"
+	ActivityMove     = "move"
+	ActivityImport   = "import"
+	ActivitySettings = "settings"
+	// 删除成员时取消其分配
+	ActivityUnassign = "unassign"
+	ActivityUndo     = "undo"
+	ActivityRedo     = "redo"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// Activity 为一条操作记录
+type Activity struct {
+	Seq int    `json:"seq"`
+	Op  string `json:"op"`
+	// IDs 为受影响的 TODO
+	IDs []int `json:"ids,omitempty"`
+	// Summary 为操作对象的内容，便于在 TODO 被删除后仍能看出改了什么
+	Summary string `json:"summary,omitempty"`
+	// Ref 为 undo / redo 所针对的操作序号
+	Ref int   `json:"ref,omitempty"`
+	At  int64 `json:"at"`
+}
+
+// undoEntry 保存一次操作前后的完整列表
+type undoEntry struct {
+	activity Activity
+	before   []TODO
+	after    []TODO
+}
+
+// record 追加活动并压入撤销栈，清空重做栈；before 为操作前的列表副本，调用方需持有写锁
+func (pp *PersonalPlan) record(op string, before []TODO, ids []int, summary string) {
+	a := pp.appendActivity(Activity{Op: op, IDs: ids, Summary: summary})
+
+	pp.undo = append(pp.undo, undoEntry{activity: a, before: before, after: slices.Clone(pp.TODOs)})
+	if len(pp.undo) > MaxPlanUndo {
+		pp.undo = slices.Delete(pp.undo, 0, len(pp.undo)-MaxPlanUndo)
+	}
+	pp.redo = nil
+}
+
+// appendActivity 分配序号并追加到日志；调用方需持有写锁
+func (pp *PersonalPlan) appendActivity(a Activity) Activity {
+	pp.lastSeq++
+	a.Seq = pp.lastSeq
+	a.At = time.Now().Unix()
+	pp.activity = append(pp.activity, a)
+	if len(pp.activity) > MaxPlanActivity {
+		pp.activity = slices.Delete(pp.activity, 0, len(pp.activity)-MaxPlanActivity)
+	}
+	return a
+}
+
+// Activity 返回 seq 小于 before 的最近 limit 条活动，新的在前；before 为 0 时从最新开始
+func (pp *PersonalPlan) Activity(before, limit int) []Activity {
+	pp.mu.RLock()
+	defer pp.mu.RUnlock()
+
+	list := []Activity{}
+	for i := len(pp.activity) - 1; i >= 0 && len(list) < limit; i-- {
+		if before == 0 || pp.activity[i].Seq < before {
+			list = append(list, pp.activity[i])
+		}
+	}
+	return list
+}
+
+// UndoDepth 返回可撤销与可重做的操作数
+func (pp *PersonalPlan) UndoDepth() (undo, redo int) {
+	pp.mu.RLock()
+	defer pp.mu.RUnlock()
+	return len(pp.undo), len(pp.redo)
+}
+
+// Undo 撤销最近的 n 个操作，返回被撤销的操作
+func (pp *PersonalPlan) Undo(n int) ([]Activity, error) {
+	return pp.step(n, &pp.undo, &pp.redo, ActivityUndo, ErrNothingToUndo)
+}
+
+// Redo 重做最近撤销的 n 个操作，返回被重做的操作
+func (pp *PersonalPlan) Redo(n int) ([]Activity, error) {
+	return pp.step(n, &pp.redo, &pp.undo, ActivityRedo, ErrNothingToRedo)
+}
+
+// step 从 from 弹出最多 n 个操作并恢复对应的列表，压入 to
+func (pp *PersonalPlan) step(n int, from, to *[]undoEntry, op string, empty error) ([]Activity, error) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	if len(*from) == 0 {
+		return nil, empty
+	}
+
+	var done []Activity
+	for ; n > 0 && len(*from) > 0; n-- {
+		e := (*from)[len(*from)-1]
+		*from = (*from)[:len(*from)-1]
+		*to = append(*to, e)
+
+		if op == ActivityUndo {
+			pp.TODOs = slices.Clone(e.before)
+		} else {
+			pp.TODOs = slices.Clone(e.after)
+		}
+		done = append(done, e.activity)
+		pp.appendActivity(Activity{Op: op, IDs: e.activity.IDs, Summary: e.activity.Summary, Ref: e.activity.Seq})
+	}
+	pp.dropStaleAssignees()
+
+	// 变化可能涉及任意条目，直接推送完整列表
+	pp.broadcast(PlanEvent{Type: PlanEventSnapshot, TODOs: pp.sorted()})
+	return done, nil
+}
+
+// dropStaleAssignees 清除已删除成员的分配，恢复旧列表后使用；调用方需持有写锁
+func (pp *PersonalPlan) dropStaleAssignees() {
+	for i := range pp.TODOs {
+		if a := pp.TODOs[i].Assignee; a != 0 && pp.findMember(a) < 0 {
+			pp.TODOs[i].Assignee = 0
+		}
+	}
+}
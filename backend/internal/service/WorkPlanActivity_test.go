@@ -0,0 +1,98 @@
+package service
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUndoAroundMemberRemoval(t *testing.T) {
+	tests := []struct {
+		name string
+		undo int
+		// 撤销后剩余的 TODO 内容与各自的负责人（按成员名，空表示未分配）
+		want     map[string]string
+		wantOps  []string
+		wantUndo int
+	}{
+		{
+			name:     "undo unassign keeps later todo",
+			undo:     1,
+			want:     map[string]string{"assigned": "", "later": ""},
+			wantOps:  []string{ActivityUnassign},
+			wantUndo: 3,
+		},
+		{
+			name:     "undo past unassign reaches later todo",
+			undo:     2,
+			want:     map[string]string{"assigned": ""},
+			wantOps:  []string{ActivityUnassign, ActivityAdd},
+			wantUndo: 2,
+		},
+		{
+			name:     "undo to assignment",
+			undo:     3,
+			want:     map[string]string{"assigned": ""},
+			wantOps:  []string{ActivityUnassign, ActivityAdd, ActivityEdit},
+			wantUndo: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pp := NewWorkPlan(WorkPlanConfig{}).NewPersonalPlan()
+			alice, err := pp.AddMember("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			todo, err := pp.AddTODO("assigned", TODOFields{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := pp.EditTODO(todo.Id, "", TODOFields{Assignee: &alice.ID}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := pp.AddTODO("later", TODOFields{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := pp.RemoveMember(alice.ID); err != nil {
+				t.Fatal(err)
+			}
+			// 新成员拿到新 ID，不会继承旧分配
+			if _, err := pp.AddMember("bob"); err != nil {
+				t.Fatal(err)
+			}
+
+			done, err := pp.Undo(tt.undo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ops []string
+			for _, a := range done {
+				ops = append(ops, a.Op)
+			}
+			if !slices.Equal(ops, tt.wantOps) {
+				t.Errorf("undone = %v, want %v", ops, tt.wantOps)
+			}
+
+			got := make(map[string]string)
+			names := make(map[int]string)
+			for _, m := range pp.Members() {
+				names[m.ID] = m.Name
+			}
+			for _, todo := range pp.GetTODOs() {
+				got[todo.Content] = names[todo.Assignee]
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("todos = %v, want %v", got, tt.want)
+			}
+			for content, assignee := range tt.want {
+				if a, ok := got[content]; !ok || a != assignee {
+					t.Errorf("%q assignee = %q (present %v), want %q", content, a, ok, assignee)
+				}
+			}
+			if undo, _ := pp.UndoDepth(); undo != tt.wantUndo {
+				t.Errorf("undo depth = %d, want %d", undo, tt.wantUndo)
+			}
+		})
+	}
+}
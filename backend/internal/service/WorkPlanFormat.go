@@ -0,0 +1,378 @@
+// WorkPlan 文本格式：GitHub Markdown 任务列表、todo.txt、CSV 与 JSON 的编码和解析
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------- Markdown ----------------
+
+// exportMarkdown 按层级输出任务列表，子任务缩进两个空格
+func exportMarkdown(todos []TODO) []byte {
+	kids := make(map[int][]TODO)
+	for _, t := range todos {
+		kids[t.ParentID] = append(kids[t.ParentID], t)
+	}
+
+	var buf bytes.Buffer
+	var walk func(parent, depth int)
+	walk = func(parent, depth int) {
+		for _, t := range kids[parent] {
+			mark := " "
+			if t.Done {
+				mark = "x"
+			}
+			fmt.Fprintf(&buf, "%s- [%s] %s\n", strings.Repeat("  ", depth), mark, oneLine(t.Content))
+			walk(t.Id, depth+1)
+		}
+	}
+	walk(0, 0)
+	return buf.Bytes()
+}
+
+var mdTaskRe = regexp.MustCompile(`^(\s*)(?:[-*+]|\d+[.)])\s+\[([ xX])\]\s+(.*)$`)
+
+// importMarkdown 只导入任务项（- [ ] / - [x]），普通列表与其他内容被忽略；按缩进还原层级
+func importMarkdown(data []byte) []importItem {
+	type level struct {
+		indent int
+		key    string
+	}
+	var (
+		items []importItem
+		stack []level
+	)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), MaxImportSize)
+	for n := 0; sc.Scan(); n++ {
+		m := mdTaskRe.FindStringSubmatch(sc.Text())
+		if m == nil {
+			continue
+		}
+		indent := len(strings.ReplaceAll(m[1], "\t", "    "))
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		it := importItem{
+			key:  strconv.Itoa(n),
+			todo: TODO{Content: m[3], Done: m[2] != " "},
+		}
+		if len(stack) > 0 {
+			it.parent = stack[len(stack)-1].key
+		}
+		stack = append(stack, level{indent, it.key})
+		items = append(items, it)
+	}
+	return items
+}
+
+// ---------------- todo.txt ----------------
+
+// todo.txt 的优先级字母，A 最高
+var todoTxtPriority = map[int]string{PriorityHigh: "A", PriorityMedium: "B", PriorityLow: "C"}
+
+// exportTodoTxt 每行一个任务：x 完成日期 创建日期 (优先级) 内容 +标签 due:日期；todo.txt 没有层级，子任务平铺输出
+func exportTodoTxt(todos []TODO) []byte {
+	var buf bytes.Buffer
+	for _, t := range todos {
+		var parts []string
+		if t.Done {
+			parts = append(parts, "x", todoTxtDate(max(t.CompletedAt, t.CreatedAt)))
+		} else if p, ok := todoTxtPriority[t.Priority]; ok {
+			parts = append(parts, "("+p+")")
+		}
+		parts = append(parts, todoTxtDate(t.CreatedAt), oneLine(t.Content))
+		for _, tag := range t.Tags {
+			parts = append(parts, "+"+strings.ReplaceAll(tag, " ", "_"))
+		}
+		if t.Due != 0 {
+			parts = append(parts, "due:"+todoTxtDate(t.Due))
+		}
+		// 完成后优先级按惯例放在 pri: 中
+		if p, ok := todoTxtPriority[t.Priority]; ok && t.Done {
+			parts = append(parts, "pri:"+p)
+		}
+		buf.WriteString(strings.Join(parts, " "))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func todoTxtDate(unix int64) string {
+	return time.Unix(unix, 0).Format(time.DateOnly)
+}
+
+var todoTxtPriorityRe = regexp.MustCompile(`^\(([A-Z])\)$`)
+
+// importTodoTxt 解析 todo.txt：+project 与 @context 转为标签，due: 转为截止时间，其余 key:value 保留在内容中
+func importTodoTxt(data []byte) []importItem {
+	var items []importItem
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), MaxImportSize)
+	for sc.Scan() {
+		words := strings.Fields(sc.Text())
+		if len(words) == 0 {
+			continue
+		}
+
+		var t TODO
+		if words[0] == "x" {
+			t.Done = true
+			words = words[1:]
+			if d, ok := todoTxtParseDate(words); ok {
+				t.CompletedAt = d
+				words = words[1:]
+			}
+		}
+		if len(words) > 0 {
+			if m := todoTxtPriorityRe.FindStringSubmatch(words[0]); m != nil {
+				t.Priority = todoTxtLetter(m[1])
+				words = words[1:]
+			}
+		}
+		if d, ok := todoTxtParseDate(words); ok {
+			t.CreatedAt = d
+			words = words[1:]
+		}
+
+		var content []string
+		for _, w := range words {
+			switch {
+			case len(w) > 1 && (w[0] == '+' || w[0] == '@'):
+				t.Tags = append(t.Tags, w[1:])
+			case strings.HasPrefix(w, "due:"):
+				if d, ok := todoTxtParseDate([]string{w[len("due:"):]}); ok {
+					t.Due = d
+					continue
+				}
+				content = append(content, w)
+			case strings.HasPrefix(w, "pri:") && len(w) == len("pri:")+1:
+				t.Priority = todoTxtLetter(w[len("pri:"):])
+			default:
+				content = append(content, w)
+			}
+		}
+		t.Content = strings.Join(content, " ")
+		items = append(items, importItem{todo: t})
+	}
+	return items
+}
+
+// todoTxtParseDate 解析 words 的第一个词
+func todoTxtParseDate(words []string) (int64, bool) {
+	if len(words) == 0 {
+		return 0, false
+	}
+	d, err := time.ParseInLocation(time.DateOnly, words[0], time.Local)
+	return d.Unix(), err == nil
+}
+
+// todoTxtLetter A 为高，B 为中，其余字母为低
+func todoTxtLetter(l string) int {
+	switch l {
+	case "A", "a":
+		return PriorityHigh
+	case "B", "b":
+		return PriorityMedium
+	}
+	return PriorityLow
+}
+
+// ---------------- CSV ----------------
+
+var csvHeader = []string{"id", "parent_id", "content", "done", "priority", "due", "tags", "assignee", "repeat", "created_at", "completed_at"}
+
+// exportCSV 时间使用 RFC3339，标签以分号分隔，负责人使用成员名字
+func exportCSV(todos []TODO, members []Member) ([]byte, error) {
+	names := make(map[int]string, len(members))
+	for _, m := range members {
+		names[m.ID] = m.Name
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(csvHeader)
+	for _, t := range todos {
+		parent := ""
+		if t.ParentID != 0 {
+			parent = strconv.Itoa(t.ParentID)
+		}
+		_ = w.Write([]string{
+			strconv.Itoa(t.Id),
+			parent,
+			t.Content,
+			strconv.FormatBool(t.Done),
+			strconv.Itoa(t.Priority),
+			csvTime(t.Due),
+			strings.Join(t.Tags, ";"),
+			names[t.Assignee],
+			t.Repeat,
+			csvTime(t.CreatedAt),
+			csvTime(t.CompletedAt),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func csvTime(unix int64) string {
+	if unix == 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).Format(time.RFC3339)
+}
+
+// importCSV 第一行为表头，按列名取值，只有 content 列是必须的
+func importCSV(data []byte) ([]importItem, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImportInvalid, err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	col := make(map[string]int)
+	for i, name := range rows[0] {
+		col[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := col["content"]; !ok {
+		return nil, fmt.Errorf("%w: csv needs a content column", ErrImportInvalid)
+	}
+
+	var items []importItem
+	for n, row := range rows[1:] {
+		get := func(name string) string {
+			if i, ok := col[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		var t TODO
+		t.Content = get("content")
+		switch strings.ToLower(get("done")) {
+		case "true", "1", "x", "yes", "done":
+			t.Done = true
+		}
+		if t.Priority, err = csvPriority(get("priority")); err != nil {
+			return nil, fmt.Errorf("%w: row %d: %v", ErrImportInvalid, n+2, err)
+		}
+		for _, f := range []struct {
+			name string
+			dst  *int64
+		}{{"due", &t.Due}, {"created_at", &t.CreatedAt}, {"completed_at", &t.CompletedAt}} {
+			if *f.dst, err = parseImportTime(get(f.name)); err != nil {
+				return nil, fmt.Errorf("%w: row %d: bad %s", ErrImportInvalid, n+2, f.name)
+			}
+		}
+		if tags := get("tags"); tags != "" {
+			t.Tags = strings.Split(tags, ";")
+		}
+		t.Repeat = get("repeat")
+
+		items = append(items, importItem{
+			key:      get("id"),
+			parent:   get("parent_id"),
+			todo:     t,
+			assignee: get("assignee"),
+		})
+	}
+	return items, nil
+}
+
+// csvPriority 接受 0-3 或 none / low / medium / high
+func csvPriority(v string) (int, error) {
+	switch strings.ToLower(v) {
+	case "", "none":
+		return PriorityNone, nil
+	case "low":
+		return PriorityLow, nil
+	case "medium":
+		return PriorityMedium, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	p, err := strconv.Atoi(v)
+	if err != nil || p < PriorityNone || p > PriorityHigh {
+		return 0, ErrTODOPriority
+	}
+	return p, nil
+}
+
+// parseImportTime 接受 RFC3339、YYYY-MM-DD 或 unix 秒，空字符串返回 0
+func parseImportTime(v string) (int64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.Unix(), nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+	return t.Unix(), err
+}
+
+// ---------------- JSON ----------------
+
+// planJSON 为 JSON 导出格式，TODO 的 assignee 对应 members 中的 ID
+type planJSON struct {
+	Version int      `json:"version"`
+	TODOs   []TODO   `json:"todos"`
+	Members []Member `json:"members"`
+}
+
+func exportJSON(todos []TODO, members []Member) ([]byte, error) {
+	return json.MarshalIndent(planJSON{Version: 1, TODOs: todos, Members: members}, "", "  ")
+}
+
+// importJSON 接受导出的对象，或只有 TODO 的数组
+func importJSON(data []byte) ([]importItem, error) {
+	var plan planJSON
+	data = bytes.TrimSpace(data)
+	var err error
+	if bytes.HasPrefix(data, []byte("[")) {
+		err = json.Unmarshal(data, &plan.TODOs)
+	} else {
+		err = json.Unmarshal(data, &plan)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImportInvalid, err)
+	}
+
+	names := make(map[int]string, len(plan.Members))
+	for _, m := range plan.Members {
+		names[m.ID] = m.Name
+	}
+	items := make([]importItem, 0, len(plan.TODOs))
+	for _, t := range plan.TODOs {
+		it := importItem{todo: t, assignee: names[t.Assignee]}
+		if t.Id != 0 {
+			it.key = strconv.Itoa(t.Id)
+		}
+		if t.ParentID != 0 {
+			it.parent = strconv.Itoa(t.ParentID)
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// oneLine 将换行替换为空格，用于按行分隔的格式
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
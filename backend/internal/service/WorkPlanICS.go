@@ -0,0 +1,416 @@
+// WorkPlan 与 iCalendar（RFC 5545）互通：导出 VTODO / VEVENT 订阅源，导入 .ics 文件
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrICSInvalid = errors.New("invalid iCalendar data")
+
+const (
+	icsTimeUTC   = "20060102T150405Z"
+	icsTimeLocal = "20060102T150405"
+	icsDate      = "20060102"
+	// 订阅客户端的建议刷新间隔
+	icsRefresh = "PT15M"
+)
+
+// ---------------- 导出 ----------------
+
+// ICS 将计划导出为日历：每个 TODO 对应一个 VTODO，有截止时间的另外生成一个 VEVENT，
+// 方便不支持 VTODO 的日历应用显示
+func (pp *PersonalPlan) ICS() []byte {
+	pp.mu.RLock()
+	todos := slices.Clone(pp.TODOs)
+	// 订阅源通过只读 hash 分享，不能暴露编辑 hash
+	hash := pp.ViewHash
+	pp.mu.RUnlock()
+	progress := progressOf(todos)
+
+	var w icsWriter
+	now := time.Now().UTC().Format(icsTimeUTC)
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", "-//DevDesk//WorkPlan//EN")
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("X-WR-CALNAME", icsEscape("WorkPlan "+hash))
+	w.line("REFRESH-INTERVAL;VALUE=DURATION", icsRefresh)
+	w.line("X-PUBLISHED-TTL", icsRefresh)
+
+	for _, t := range todos {
+		uid := todoUID(hash, t.Id)
+
+		w.line("BEGIN", "VTODO")
+		w.line("UID", uid)
+		w.line("DTSTAMP", now)
+		w.line("CREATED", icsTime(t.CreatedAt))
+		w.line("SUMMARY", icsEscape(t.Content))
+		if t.Due != 0 {
+			w.line("DUE", icsTime(t.Due))
+		}
+		if t.Done {
+			w.line("STATUS", "COMPLETED")
+			if t.CompletedAt != 0 {
+				w.line("COMPLETED", icsTime(t.CompletedAt))
+			}
+		} else {
+			w.line("STATUS", "NEEDS-ACTION")
+		}
+		w.line("PERCENT-COMPLETE", strconv.Itoa(progress[t.Id]))
+		if p := icsPriority(t.Priority); p != 0 {
+			w.line("PRIORITY", strconv.Itoa(p))
+		}
+		if len(t.Tags) > 0 {
+			w.line("CATEGORIES", icsJoin(t.Tags))
+		}
+		if t.ParentID != 0 {
+			w.line("RELATED-TO", todoUID(hash, t.ParentID))
+		}
+		w.line("END", "VTODO")
+
+		if t.Due == 0 {
+			continue
+		}
+		summary := t.Content
+		if t.Done {
+			summary = "✔ " + summary
+		}
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", "due-"+uid)
+		w.line("DTSTAMP", now)
+		w.line("DTSTART", icsTime(t.Due))
+		w.line("DTEND", icsTime(t.Due))
+		w.line("SUMMARY", icsEscape(summary))
+		w.line("TRANSP", "TRANSPARENT")
+		if len(t.Tags) > 0 {
+			w.line("CATEGORIES", icsJoin(t.Tags))
+		}
+		// 导入时据此跳过，避免与 VTODO 重复
+		w.line("RELATED-TO;RELTYPE=SIBLING", uid)
+		w.line("END", "VEVENT")
+	}
+
+	w.line("END", "VCALENDAR")
+	return w.buf.Bytes()
+}
+
+func todoUID(hash string, id int) string {
+	return fmt.Sprintf("%d@%s.workplan.devdesk", id, hash)
+}
+
+// icsPriority 映射到 RFC 5545 的 1（最高）- 9（最低），0 表示未定义
+func icsPriority(p int) int {
+	switch p {
+	case PriorityHigh:
+		return 1
+	case PriorityMedium:
+		return 5
+	case PriorityLow:
+		return 9
+	}
+	return 0
+}
+
+func icsTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(icsTimeUTC)
+}
+
+type icsWriter struct {
+	buf bytes.Buffer
+}
+
+// line 写入一行内容，超过 75 字节时按 UTF-8 字符边界折行
+func (w *icsWriter) line(name, value string) {
+	s := name + ":" + value
+	for limit := 75; len(s) > limit; limit = 74 {
+		i := limit
+		for i > 0 && !utf8.RuneStart(s[i]) {
+			i--
+		}
+		w.buf.WriteString(s[:i])
+		w.buf.WriteString("\r\n ")
+		s = s[i:]
+	}
+	w.buf.WriteString(s)
+	w.buf.WriteString("\r\n")
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icsEscape(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+func icsJoin(values []string) string {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = icsEscape(v)
+	}
+	return strings.Join(escaped, ",")
+}
+
+// ---------------- 导入 ----------------
+
+// icsProp 为一行内容解析后的属性
+type icsProp struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// icsItem 为从 VTODO / VEVENT 中提取的字段
+type icsItem struct {
+	uid       string
+	summary   string
+	due       int64
+	done      bool
+	completed int64
+	created   int64
+	priority  int
+	tags      []string
+	parent    string
+	// sibling 为导出时 VEVENT 指向的 VTODO
+	sibling string
+}
+
+// icsImportItems 将 .ics 中的 VTODO 与 VEVENT 转为导入条目；RELATED-TO 指向同一文件中的条目时保留父子关系，
+// 本服务导出的 VEVENT 会被跳过
+func icsImportItems(data []byte) ([]importItem, error) {
+	items, err := parseICS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	todoUIDs := make(map[string]bool)
+	for _, it := range items {
+		if it.sibling == "" {
+			todoUIDs[it.uid] = true
+		}
+	}
+
+	var list []importItem
+	for _, it := range items {
+		if it.sibling != "" && todoUIDs[it.sibling] {
+			continue
+		}
+		list = append(list, importItem{
+			key:    it.uid,
+			parent: it.parent,
+			todo: TODO{
+				Content:     it.summary,
+				Done:        it.done,
+				Due:         it.due,
+				Priority:    it.priority,
+				Tags:        it.tags,
+				CreatedAt:   it.created,
+				CompletedAt: it.completed,
+			},
+		})
+	}
+	return list, nil
+}
+
+// parseICS 提取 VCALENDAR 中的 VTODO 与 VEVENT，忽略其中嵌套的 VALARM 等组件
+func parseICS(data []byte) ([]icsItem, error) {
+	lines := unfoldICS(data)
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, ErrICSInvalid
+	}
+
+	var (
+		items []icsItem
+		cur   *icsItem
+		stack []string
+	)
+	for _, l := range lines {
+		p, ok := parseICSLine(l)
+		if !ok {
+			return nil, ErrICSInvalid
+		}
+		switch p.name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(p.value))
+			if len(stack) == 2 && (stack[1] == "VTODO" || stack[1] == "VEVENT") {
+				cur = &icsItem{}
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(p.value) {
+				return nil, ErrICSInvalid
+			}
+			if len(stack) == 2 && cur != nil {
+				items = append(items, *cur)
+				cur = nil
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if cur == nil || len(stack) != 2 {
+			continue
+		}
+		if err := cur.set(p, stack[1]); err != nil {
+			return nil, err
+		}
+	}
+	if len(stack) != 0 {
+		return nil, ErrICSInvalid
+	}
+	return items, nil
+}
+
+func (it *icsItem) set(p icsProp, kind string) error {
+	switch p.name {
+	case "UID":
+		it.uid = p.value
+	case "SUMMARY":
+		it.summary = strings.TrimSpace(icsUnescape(p.value))
+	case "DUE", "DTSTART":
+		// VTODO 使用 DUE，VEVENT 使用开始时间
+		if (p.name == "DUE") != (kind == "VTODO") {
+			return nil
+		}
+		due, err := icsParseTime(p)
+		if err != nil {
+			return err
+		}
+		it.due = due
+	case "STATUS":
+		it.done = it.done || strings.EqualFold(p.value, "COMPLETED")
+	case "COMPLETED":
+		completed, err := icsParseTime(p)
+		if err != nil {
+			return err
+		}
+		it.done, it.completed = true, completed
+	case "CREATED":
+		// 格式不正确时使用导入时间
+		it.created, _ = icsParseTime(p)
+	case "PRIORITY":
+		n, _ := strconv.Atoi(p.value)
+		switch {
+		case n >= 1 && n <= 4:
+			it.priority = PriorityHigh
+		case n == 5:
+			it.priority = PriorityMedium
+		case n >= 6 && n <= 9:
+			it.priority = PriorityLow
+		}
+	case "CATEGORIES":
+		it.tags = append(it.tags, icsSplit(p.value)...)
+	case "RELATED-TO":
+		switch strings.ToUpper(p.params["RELTYPE"]) {
+		case "", "PARENT":
+			it.parent = p.value
+		case "SIBLING":
+			it.sibling = p.value
+		}
+	}
+	return nil
+}
+
+// unfoldICS 合并折行并去掉空行
+func unfoldICS(data []byte) []string {
+	var lines []string
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), MaxImportSize)
+	for sc.Scan() {
+		l := strings.TrimSuffix(sc.Text(), "\r")
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		if strings.TrimSpace(l) != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// parseICSLine 解析 name;param=value;...:value，参数值可以加引号
+func parseICSLine(l string) (icsProp, bool) {
+	p := icsProp{params: make(map[string]string)}
+	quoted := false
+	start := -1
+	for i, r := range l {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == ';' || r == ':':
+			field := l[start+1 : i]
+			if start < 0 {
+				p.name = strings.ToUpper(field)
+			} else if k, v, ok := strings.Cut(field, "="); ok {
+				p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+			}
+			start = i
+			if r == ':' {
+				p.value = l[i+1:]
+				return p, p.name != ""
+			}
+		}
+	}
+	return p, false
+}
+
+// icsParseTime 支持 UTC、带 TZID 的本地时间、浮动时间与 VALUE=DATE 日期
+func icsParseTime(p icsProp) (int64, error) {
+	loc := time.Local
+	if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := icsParseValue(p.value, loc)
+	if err != nil {
+		return 0, fmt.Errorf("%w: bad %s %q", ErrICSInvalid, p.name, p.value)
+	}
+	return t.Unix(), nil
+}
+
+// icsParseValue 解析 DATE-TIME 或 DATE 值；以 Z 结尾的是 UTC 时间，其余按 loc 解释
+func icsParseValue(v string, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(v, "Z") {
+		return time.Parse(icsTimeUTC, v)
+	}
+	t, err := time.ParseInLocation(icsTimeLocal, v, loc)
+	if err != nil {
+		t, err = time.ParseInLocation(icsDate, v, loc)
+	}
+	return t, err
+}
+
+var icsUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func icsUnescape(s string) string {
+	return icsUnescaper.Replace(s)
+}
+
+// icsSplit 按未转义的逗号拆分多值属性
+func icsSplit(s string) []string {
+	var values []string
+	var cur strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			cur.WriteByte(s[i])
+			cur.WriteByte(s[i+1])
+			i++
+		case s[i] == ',':
+			values = append(values, icsUnescape(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(s[i])
+		}
+	}
+	return append(values, icsUnescape(cur.String()))
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withLocal 在测试期间把 time.Local 换成非 UTC 时区，模拟 TZ=Asia/Shanghai 的服务器
+func withLocal(t *testing.T) {
+	t.Helper()
+	old := time.Local
+	time.Local = time.FixedZone("UTC+8", 8*60*60)
+	t.Cleanup(func() { time.Local = old })
+}
+
+func TestICSParseTime(t *testing.T) {
+	withLocal(t)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tzdata unavailable:", err)
+	}
+
+	tests := []struct {
+		name    string
+		line    string
+		want    time.Time
+		wantErr bool
+	}{
+		{"utc", "DUE:20270115T083000Z", time.Date(2027, 1, 15, 8, 30, 0, 0, time.UTC), false},
+		{"utc ignores tzid", "DUE;TZID=America/New_York:20270115T083000Z", time.Date(2027, 1, 15, 8, 30, 0, 0, time.UTC), false},
+		{"floating uses server zone", "DUE:20270115T083000", time.Date(2027, 1, 15, 8, 30, 0, 0, time.Local), false},
+		{"tzid", "DUE;TZID=America/New_York:20270115T083000", time.Date(2027, 1, 15, 8, 30, 0, 0, newYork), false},
+		{"quoted tzid", `DUE;TZID="America/New_York":20270115T083000`, time.Date(2027, 1, 15, 8, 30, 0, 0, newYork), false},
+		{"date", "DUE;VALUE=DATE:20270115", time.Date(2027, 1, 15, 0, 0, 0, 0, time.Local), false},
+		{"garbage", "DUE:tomorrow", time.Time{}, true},
+		{"lowercase z is not utc", "DUE:20270115T083000z", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := parseICSLine(tt.line)
+			if !ok {
+				t.Fatalf("parseICSLine(%q) failed", tt.line)
+			}
+			got, err := icsParseTime(p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want.Unix() {
+				t.Errorf("got %v, want %v", time.Unix(got, 0).UTC(), tt.want.UTC())
+			}
+		})
+	}
+}
+
+func TestICSRoundTrip(t *testing.T) {
+	withLocal(t)
+	wp := NewWorkPlan(WorkPlanConfig{})
+	src := wp.NewPersonalPlan()
+
+	due := int64(1800000000)
+	high, tags := PriorityHigh, []string{"work", "a,b; c"}
+	parent, err := src.AddTODO("Ship release; v2, final", TODOFields{Due: &due, Priority: &high, Tags: &tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := src.AddTODO(strings.TrimSpace(strings.Repeat("长内容需要折行 ", 20)), TODOFields{ParentID: &parent.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.SetTODODone(child.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.AddTODO("line one\nline two", TODOFields{}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := src.ICS()
+	for _, l := range strings.Split(string(data), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line longer than 75 bytes: %q", l)
+		}
+	}
+
+	dst := wp.NewPersonalPlan()
+	res, err := dst.Import(FormatICS, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 导出与导入都按列表顺序
+	want, got := src.TODOs, res.TODOs
+	// VEVENT 与对应的 VTODO 重复，导入时应被跳过
+	if len(got) != len(want) {
+		t.Fatalf("imported %d todos, want %d", len(got), len(want))
+	}
+
+	ids := make(map[int]int) // 原 ID -> 新 ID
+	for i := range want {
+		ids[want[i].Id] = got[i].Id
+	}
+	for i, w := range want {
+		g := got[i]
+		switch {
+		case g.Content != w.Content:
+			t.Errorf("%d: content = %q, want %q", i, g.Content, w.Content)
+		case g.Due != w.Due:
+			t.Errorf("%d: due = %d, want %d", i, g.Due, w.Due)
+		case g.Done != w.Done || g.CompletedAt != w.CompletedAt:
+			t.Errorf("%d: done = %v@%d, want %v@%d", i, g.Done, g.CompletedAt, w.Done, w.CompletedAt)
+		case g.CreatedAt != w.CreatedAt:
+			t.Errorf("%d: created = %d, want %d", i, g.CreatedAt, w.CreatedAt)
+		case g.Priority != w.Priority:
+			t.Errorf("%d: priority = %d, want %d", i, g.Priority, w.Priority)
+		case !slices.Equal(g.Tags, w.Tags):
+			t.Errorf("%d: tags = %q, want %q", i, g.Tags, w.Tags)
+		case g.ParentID != ids[w.ParentID]:
+			t.Errorf("%d: parent = %d, want %d", i, g.ParentID, ids[w.ParentID])
+		}
+	}
+}
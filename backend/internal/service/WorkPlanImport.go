@@ -0,0 +1,214 @@
+// WorkPlan 导入导出：按格式分发，各格式解析出的条目统一由 importItems 追加到计划
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 导入导出格式
+const (
+	FormatMarkdown = "md"
+	FormatTodoTxt  = "todotxt"
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatICS      = "ics"
+)
+
+// 导入文件的大小上限
+const MaxImportSize = 1 << 20
+
+var (
+	ErrPlanFormat     = errors.New("format must be md, todotxt, csv, json or ics")
+	ErrImportInvalid  = errors.New("invalid import data")
+	ErrImportTooLarge = fmt.Errorf("import data exceeds %d bytes", MaxImportSize)
+)
+
+// PlanImport 为导入结果
+type PlanImport struct {
+	TODOs []TODO `json:"todos"`
+	// Skipped 为没有内容而被跳过的条目数
+	Skipped int `json:"skipped"`
+}
+
+// importItem 为从文件中解析出的一个条目
+type importItem struct {
+	// key / parent 为文件内的标识，用于还原父子关系，可以为空
+	key    string
+	parent string
+	// todo 中的 Id、ParentID、SeriesID、Assignee 会被忽略
+	todo TODO
+	// assignee 为负责人名字，与现有成员匹配，匹配不到时不分配
+	assignee string
+}
+
+// FormatFromFilename 按扩展名推断格式，无法推断时返回空字符串
+func FormatFromFilename(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".md"), strings.HasSuffix(name, ".markdown"):
+		return FormatMarkdown
+	case strings.HasSuffix(name, ".txt"):
+		return FormatTodoTxt
+	case strings.HasSuffix(name, ".csv"):
+		return FormatCSV
+	case strings.HasSuffix(name, ".json"):
+		return FormatJSON
+	case strings.HasSuffix(name, ".ics"), strings.HasSuffix(name, ".ical"):
+		return FormatICS
+	}
+	return ""
+}
+
+// Export 以指定格式导出全部 TODO，按列表中的顺序
+func (pp *PersonalPlan) Export(format string) ([]byte, error) {
+	if format == FormatICS {
+		return pp.ICS(), nil
+	}
+
+	pp.mu.RLock()
+	todos := slices.Clone(pp.TODOs)
+	members := append([]Member{}, pp.members...)
+	pp.mu.RUnlock()
+
+	switch format {
+	case FormatMarkdown:
+		return exportMarkdown(todos), nil
+	case FormatTodoTxt:
+		return exportTodoTxt(todos), nil
+	case FormatCSV:
+		return exportCSV(todos, members)
+	case FormatJSON:
+		return exportJSON(todos, members)
+	}
+	return nil, ErrPlanFormat
+}
+
+// Import 解析指定格式的数据并追加为 TODO，全部成功或全部不导入
+func (pp *PersonalPlan) Import(format string, data []byte) (*PlanImport, error) {
+	if len(data) > MaxImportSize {
+		return nil, ErrImportTooLarge
+	}
+
+	var (
+		items []importItem
+		err   error
+	)
+	switch format {
+	case FormatMarkdown:
+		items = importMarkdown(data)
+	case FormatTodoTxt:
+		items = importTodoTxt(data)
+	case FormatCSV:
+		items, err = importCSV(data)
+	case FormatJSON:
+		items, err = importJSON(data)
+	case FormatICS:
+		items, err = icsImportItems(data)
+	default:
+		return nil, ErrPlanFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pp.importItems(items)
+}
+
+// importItems 校验并追加条目，父子关系中会形成环的被忽略
+func (pp *PersonalPlan) importItems(items []importItem) (*PlanImport, error) {
+	res := &PlanImport{TODOs: []TODO{}}
+	items = slices.DeleteFunc(items, func(it importItem) bool {
+		if strings.TrimSpace(it.todo.Content) == "" {
+			res.Skipped++
+			return true
+		}
+		return false
+	})
+
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	if pp.maxTODOs > 0 && len(pp.TODOs)+len(items) > pp.maxTODOs {
+		return nil, fmt.Errorf("%w (max %d)", ErrTODOTooMany, pp.maxTODOs)
+	}
+
+	now := time.Now().Unix()
+	ids := make(map[string]int, len(items))
+	start := len(pp.TODOs)
+	before := slices.Clone(pp.TODOs)
+	for _, it := range items {
+		t := it.todo
+		t.Id, t.ParentID, t.SeriesID, t.Assignee = pp.nextID, 0, 0, 0
+		t.Content = strings.TrimSpace(t.Content)
+		t.Tags = importTags(t.Tags)
+		t.Priority = min(max(t.Priority, PriorityNone), PriorityHigh)
+		// 不支持的重复规则直接丢弃
+		if t.Repeat, _ = normalizeRepeat(t.Repeat); t.Repeat != "" {
+			t.SeriesID = t.Id
+		}
+		if t.CreatedAt <= 0 {
+			t.CreatedAt = now
+		}
+		switch {
+		case !t.Done:
+			t.CompletedAt = 0
+		case t.CompletedAt <= 0:
+			t.CompletedAt = now
+		}
+		for _, m := range pp.members {
+			if it.assignee != "" && strings.EqualFold(m.Name, strings.TrimSpace(it.assignee)) {
+				t.Assignee = m.ID
+			}
+		}
+		if it.key != "" {
+			ids[it.key] = t.Id
+		}
+		pp.nextID++
+		pp.TODOs = append(pp.TODOs, t)
+	}
+
+	for i, it := range items {
+		parentID, ok := ids[it.parent]
+		if !ok || it.parent == "" {
+			continue
+		}
+		t := &pp.TODOs[start+i]
+		if pp.checkParent(t.Id, parentID) == nil {
+			t.ParentID = parentID
+		}
+	}
+
+	if len(items) == 0 {
+		return res, nil
+	}
+	// 父任务都是导入的条目，汇总后再复制结果；汇总可能插入重复任务的下一次实例，按 ID 查找
+	imported := make([]int, len(items))
+	for i := range items {
+		imported[i] = pp.TODOs[start+i].Id
+	}
+	for _, id := range imported {
+		pp.rollUp(pp.TODOs[pp.find(id)].ParentID)
+	}
+	res.TODOs = append(res.TODOs, pp.TODOs[start:]...)
+	pp.record(ActivityImport, before, imported, "")
+	pp.broadcast(PlanEvent{Type: PlanEventAdd, TODOs: slices.Clone(res.TODOs)})
+	return res, nil
+}
+
+// importTags 规则与 TODOFields 相同，但过长的标签被丢弃、超出上限的被截断，而不是报错
+func importTags(raw []string) []string {
+	var tags []string
+	for _, tag := range raw {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > MaxTODOTagSize || slices.Contains(tags, tag) {
+			continue
+		}
+		if tags = append(tags, tag); len(tags) == MaxTODOTags {
+			break
+		}
+	}
+	return tags
+}
@@ -0,0 +1,122 @@
+// WorkPlan 查询：按标签 / 状态 / 截止时间过滤，按优先级 / 截止时间排序
+package service
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	TODOStatusAll  = "all"
+	TODOStatusOpen = "open"
+	TODOStatusDone = "done"
+
+	// TODOSortManual 为列表中的手动顺序
+	TODOSortManual   = "manual"
+	TODOSortPriority = "priority"
+	TODOSortDue      = "due"
+	TODOSortCreated  = "created"
+)
+
+var (
+	ErrTODOStatus = errors.New("status must be all, open or done")
+	ErrTODOSort   = errors.New("sort must be manual, priority, due or created")
+)
+
+// TODOQuery 为列表查询参数，零值返回全部 TODO
+type TODOQuery struct {
+	// Tags 中的标签需全部命中
+	Tags   []string
+	Status string
+	// DueFrom / DueTo 按截止时间过滤（闭区间），设置后没有截止时间的 TODO 被排除
+	DueFrom int64
+	DueTo   int64
+	// Assignee 为负责人的成员 ID，0 表示不过滤；Unassigned 只返回未分配的
+	Assignee   int
+	Unassigned bool
+	Sort       string
+}
+
+func (q *TODOQuery) normalize() error {
+	switch q.Status {
+	case "":
+		q.Status = TODOStatusAll
+	case TODOStatusAll, TODOStatusOpen, TODOStatusDone:
+	default:
+		return ErrTODOStatus
+	}
+	switch q.Sort {
+	case "":
+		q.Sort = TODOSortManual
+	case TODOSortManual, TODOSortPriority, TODOSortDue, TODOSortCreated:
+	default:
+		return ErrTODOSort
+	}
+	for i, tag := range q.Tags {
+		q.Tags[i] = strings.ToLower(strings.TrimSpace(tag))
+	}
+	return nil
+}
+
+func (q *TODOQuery) match(t TODO) bool {
+	switch {
+	case q.Status == TODOStatusOpen && t.Done,
+		q.Status == TODOStatusDone && !t.Done:
+		return false
+	case (q.DueFrom > 0 || q.DueTo > 0) && t.Due == 0,
+		q.DueFrom > 0 && t.Due < q.DueFrom,
+		q.DueTo > 0 && t.Due > q.DueTo:
+		return false
+	case q.Unassigned && t.Assignee != 0,
+		q.Assignee != 0 && t.Assignee != q.Assignee:
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !slices.Contains(t.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// less 为各排序方式的比较函数，相等时保持手动顺序
+func (q *TODOQuery) less(a, b TODO) bool {
+	switch q.Sort {
+	case TODOSortPriority:
+		return a.Priority > b.Priority
+	case TODOSortDue:
+		// 没有截止时间的排在最后
+		if a.Due == 0 || b.Due == 0 {
+			return a.Due != 0 && b.Due == 0
+		}
+		return a.Due < b.Due
+	case TODOSortCreated:
+		return a.CreatedAt > b.CreatedAt
+	}
+	return false
+}
+
+// QueryTODOs 返回满足条件的 TODO；未完成的在前，已完成的在后，组内按 Sort 排序
+func (pp *PersonalPlan) QueryTODOs(q TODOQuery) ([]TODO, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+
+	todos := []TODO{}
+	for _, t := range pp.GetTODOs() {
+		if q.match(t) {
+			todos = append(todos, t)
+		}
+	}
+
+	sort.SliceStable(todos, func(i, j int) bool {
+		a, b := todos[i], todos[j]
+		if a.Done != b.Done {
+			return !a.Done
+		}
+		return q.less(a, b)
+	})
+	return todos, nil
+}
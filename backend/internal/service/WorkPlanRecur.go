@@ -0,0 +1,323 @@
+// WorkPlan 重复任务：支持 RRULE 的一个子集，完成时自动创建下一次实例，已完成的实例保留为历史
+package service
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RepeatDaily    = "FREQ=DAILY"
+	RepeatWeekly   = "FREQ=WEEKLY"
+	RepeatMonthly  = "FREQ=MONTHLY"
+	RepeatYearly   = "FREQ=YEARLY"
+	RepeatWeekdays = "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"
+
+	// 计算下一次时最多检查的周期数
+	maxRepeatSteps = 1000
+)
+
+var ErrTODORepeat = errors.New("unsupported repeat rule: use daily, weekdays, weekly, biweekly, monthly, yearly " +
+	"or RRULE with FREQ=DAILY|WEEKLY|MONTHLY|YEARLY and optional INTERVAL, BYDAY (daily/weekly), BYMONTHDAY (monthly), COUNT, UNTIL")
+
+// repeatPresets 为 repeat 字段可用的简写
+var repeatPresets = map[string]string{
+	"daily":    RepeatDaily,
+	"weekdays": RepeatWeekdays,
+	"weekly":   RepeatWeekly,
+	"biweekly": RepeatWeekly + ";INTERVAL=2",
+	"monthly":  RepeatMonthly,
+	"yearly":   RepeatYearly,
+}
+
+var rruleDays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// rrule 为解析后的重复规则；日期按服务器本地时区计算，保持原实例的时刻
+type rrule struct {
+	freq     string
+	interval int
+	byDay    []time.Weekday
+	// byMonthDay 为每月的第几天，-1 表示最后一天，0 表示与原实例相同
+	byMonthDay int
+	// count 为整个系列的实例总数上限，until 为最后一次的截止时间，0 表示不限制
+	count int
+	until int64
+}
+
+// normalizeRepeat 将简写或 RRULE（可带 RRULE: 前缀）转为规范形式，空字符串表示不重复
+func normalizeRepeat(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+	if preset, ok := repeatPresets[strings.ToLower(s)]; ok {
+		s = preset
+	}
+	r, err := parseRRule(s)
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+func parseRRule(s string) (rrule, error) {
+	s = strings.TrimPrefix(strings.ToUpper(s), "RRULE:")
+	r := rrule{interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return r, ErrTODORepeat
+		}
+		var err error
+		switch k {
+		case "FREQ":
+			r.freq = v
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(v)
+			if r.interval < 1 || r.interval > 366 {
+				err = ErrTODORepeat
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(v, ",") {
+				i := slices.Index(rruleDays, d)
+				if i < 0 {
+					return r, ErrTODORepeat
+				}
+				if !slices.Contains(r.byDay, time.Weekday(i)) {
+					r.byDay = append(r.byDay, time.Weekday(i))
+				}
+			}
+		case "BYMONTHDAY":
+			r.byMonthDay, err = strconv.Atoi(v)
+			if r.byMonthDay != -1 && (r.byMonthDay < 1 || r.byMonthDay > 31) {
+				err = ErrTODORepeat
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(v)
+			if r.count < 1 {
+				err = ErrTODORepeat
+			}
+		case "UNTIL":
+			// 规范形式总是 UTC，不带 Z 的按服务器时区解释
+			var t time.Time
+			t, err = icsParseValue(v, time.Local)
+			if len(v) == len(icsDate) {
+				// 只有日期时包含当天
+				t = t.AddDate(0, 0, 1).Add(-time.Second)
+			}
+			r.until = t.Unix()
+		default:
+			err = ErrTODORepeat
+		}
+		if err != nil {
+			return r, ErrTODORepeat
+		}
+	}
+
+	switch {
+	case r.freq != "DAILY" && r.freq != "WEEKLY" && r.freq != "MONTHLY" && r.freq != "YEARLY",
+		len(r.byDay) > 0 && r.freq != "DAILY" && r.freq != "WEEKLY",
+		r.byMonthDay != 0 && r.freq != "MONTHLY",
+		r.count > 0 && r.until > 0:
+		return r, ErrTODORepeat
+	}
+	sort.Slice(r.byDay, func(i, j int) bool { return weekOffset(r.byDay[i]) < weekOffset(r.byDay[j]) })
+	return r, nil
+}
+
+// String 返回规范形式，字段顺序固定
+func (r rrule) String() string {
+	parts := []string{"FREQ=" + r.freq}
+	if r.interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.interval))
+	}
+	if len(r.byDay) > 0 {
+		days := make([]string, len(r.byDay))
+		for i, d := range r.byDay {
+			days[i] = rruleDays[d]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.byMonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.byMonthDay))
+	}
+	if r.count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.count))
+	}
+	if r.until > 0 {
+		parts = append(parts, "UNTIL="+icsTime(r.until))
+	}
+	return strings.Join(parts, ";")
+}
+
+// weekOffset 返回从周一开始的偏移
+func weekOffset(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
+
+// next 返回 anchor 所在系列中晚于 after 的第一次，超出 UNTIL 时返回 false
+func (r rrule) next(anchor, after time.Time) (time.Time, bool) {
+	// 跳过 after 之前的大部分周期
+	var k int
+	switch r.freq {
+	case "DAILY":
+		k = int(after.Sub(anchor).Hours()/24) / r.interval
+	case "WEEKLY":
+		k = int(after.Sub(anchor).Hours()/24/7) / r.interval
+	case "MONTHLY":
+		k = ((after.Year()-anchor.Year())*12 + int(after.Month()-anchor.Month())) / r.interval
+	case "YEARLY":
+		k = (after.Year() - anchor.Year()) / r.interval
+	}
+	k = max(k-1, 0)
+
+	y, m, d := anchor.Date()
+	h, mi, s := anchor.Clock()
+	loc := anchor.Location()
+	for end := k + maxRepeatSteps; k < end; k++ {
+		var candidates []time.Time
+		switch r.freq {
+		case "DAILY":
+			c := time.Date(y, m, d+k*r.interval, h, mi, s, 0, loc)
+			if len(r.byDay) == 0 || slices.Contains(r.byDay, c.Weekday()) {
+				candidates = append(candidates, c)
+			}
+		case "WEEKLY":
+			monday := d - weekOffset(anchor.Weekday()) + 7*k*r.interval
+			days := r.byDay
+			if len(days) == 0 {
+				days = []time.Weekday{anchor.Weekday()}
+			}
+			for _, wd := range days {
+				candidates = append(candidates, time.Date(y, m, monday+weekOffset(wd), h, mi, s, 0, loc))
+			}
+		case "MONTHLY":
+			first := time.Date(y, m+time.Month(k*r.interval), 1, h, mi, s, 0, loc)
+			last := first.AddDate(0, 1, -1).Day()
+			day := r.byMonthDay
+			switch {
+			case day == 0:
+				day = d
+			case day == -1:
+				day = last
+			}
+			// 没有这一天的月份跳过
+			if day <= last {
+				candidates = append(candidates, first.AddDate(0, 0, day-1))
+			}
+		case "YEARLY":
+			c := time.Date(y+k*r.interval, m, d, h, mi, s, 0, loc)
+			if c.Month() == m {
+				candidates = append(candidates, c)
+			}
+		}
+
+		for _, c := range candidates {
+			if !c.After(after) {
+				continue
+			}
+			if r.until > 0 && c.Unix() > r.until {
+				return time.Time{}, false
+			}
+			return c, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// nextInstance 为即将完成的第 i 个 TODO 生成下一次实例；系列已有未完成实例或已结束时返回 false。
+// 调用方需持有写锁
+func (pp *PersonalPlan) nextInstance(i int, now time.Time) (TODO, bool, error) {
+	t := pp.TODOs[i]
+	r, err := parseRRule(t.Repeat)
+	if err != nil {
+		return TODO{}, false, err
+	}
+
+	instances := 0
+	for _, o := range pp.TODOs {
+		if o.SeriesID != t.SeriesID {
+			continue
+		}
+		if !o.Done && o.Id != t.Id {
+			return TODO{}, false, nil
+		}
+		instances++
+	}
+	if r.count > 0 && instances >= r.count {
+		return TODO{}, false, nil
+	}
+
+	// 有截止时间时按原计划推算，逾期完成则跳到当前时间之后的第一次
+	anchor := now
+	if t.Due != 0 {
+		anchor = time.Unix(t.Due, 0)
+	}
+	due, ok := r.next(anchor, later(anchor, now))
+	if !ok {
+		return TODO{}, false, nil
+	}
+	return TODO{
+		Id:        pp.nextID,
+		Content:   t.Content,
+		ParentID:  t.ParentID,
+		Due:       due.Unix(),
+		Priority:  t.Priority,
+		Tags:      slices.Clone(t.Tags),
+		Assignee:  t.Assignee,
+		CreatedAt: now.Unix(),
+		Repeat:    t.Repeat,
+		SeriesID:  t.SeriesID,
+	}, true, nil
+}
+
+// spawnNext 在刚完成的第 i 个重复 TODO 之后插入下一次实例。计划已达到 TODO 上限时不生成，
+// 返回 skipped，完成本身不受影响。调用方需持有写锁，插入后指向 pp.TODOs 的指针不再有效
+func (pp *PersonalPlan) spawnNext(i int, now time.Time) (next *TODO, skipped bool) {
+	n, ok, err := pp.nextInstance(i, now)
+	if err != nil || !ok {
+		// Repeat 写入时已校验，解析失败只可能来自旧数据，按系列结束处理
+		return nil, false
+	}
+	if pp.maxTODOs > 0 && len(pp.TODOs) >= pp.maxTODOs {
+		return nil, true
+	}
+	pp.nextID++
+	pp.TODOs = slices.Insert(pp.TODOs, i+1, n)
+	return &n, false
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// History 返回 id 所在重复系列的全部实例，按创建顺序排列；不是重复任务时只有它自己
+func (pp *PersonalPlan) History(id int) ([]TODO, error) {
+	pp.mu.RLock()
+	defer pp.mu.RUnlock()
+
+	i := pp.find(id)
+	if i < 0 {
+		return nil, ErrTODONotFound
+	}
+	series := pp.TODOs[i].SeriesID
+	if series == 0 {
+		return []TODO{pp.TODOs[i]}, nil
+	}
+
+	var todos []TODO
+	for _, t := range pp.TODOs {
+		if t.SeriesID == series {
+			todos = append(todos, t)
+		}
+	}
+	sort.Slice(todos, func(i, j int) bool { return todos[i].Id < todos[j].Id })
+	return todos, nil
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeRepeat(t *testing.T) {
+	withLocal(t)
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: ""},
+		{in: "Weekdays", want: RepeatWeekdays},
+		{in: "biweekly", want: "FREQ=WEEKLY;INTERVAL=2"},
+		{in: "rrule:freq=weekly;byday=fr,mo,mo", want: "FREQ=WEEKLY;BYDAY=MO,FR"},
+		{in: "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3", want: "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3"},
+		// UTC 的 UNTIL 保持不变，重复保存不会漂移
+		{in: "FREQ=DAILY;UNTIL=20260101T000000Z", want: "FREQ=DAILY;UNTIL=20260101T000000Z"},
+		// 浮动时间与日期按服务器时区（UTC+8）解释，日期包含当天
+		{in: "FREQ=DAILY;UNTIL=20260101T080000", want: "FREQ=DAILY;UNTIL=20260101T000000Z"},
+		{in: "FREQ=DAILY;UNTIL=20260101", want: "FREQ=DAILY;UNTIL=20260101T155959Z"},
+		{in: "FREQ=HOURLY", wantErr: true},
+		{in: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		{in: "FREQ=MONTHLY;BYDAY=MO", wantErr: true},
+		{in: "FREQ=WEEKLY;BYMONTHDAY=1", wantErr: true},
+		{in: "FREQ=DAILY;COUNT=2;UNTIL=20260101T000000Z", wantErr: true},
+		{in: "FREQ=DAILY;UNTIL=someday", wantErr: true},
+		{in: "FREQ=DAILY;BYSETPOS=1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := normalizeRepeat(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			// 规范形式再次解析必须得到相同结果
+			if again, err := normalizeRepeat(got); err != nil || again != got {
+				t.Errorf("re-normalize = %q, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestRRuleNext(t *testing.T) {
+	withLocal(t)
+	at := func(y int, m time.Month, d, h int) time.Time {
+		return time.Date(y, m, d, h, 0, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name   string
+		rule   string
+		anchor time.Time
+		after  time.Time // 零值表示 anchor
+		want   time.Time // 零值表示系列已结束
+	}{
+		{"daily interval", "FREQ=DAILY;INTERVAL=2", at(2026, 3, 1, 9), time.Time{}, at(2026, 3, 3, 9)},
+		{"daily far after", "FREQ=DAILY", at(2026, 3, 1, 9), at(2027, 1, 1, 0), at(2027, 1, 1, 9)},
+		{"weekdays skip weekend", RepeatWeekdays, at(2026, 3, 6, 9), time.Time{}, at(2026, 3, 9, 9)},
+		{"weekly byday", "FREQ=WEEKLY;BYDAY=MO,TH", at(2026, 3, 5, 9), time.Time{}, at(2026, 3, 9, 9)},
+		{"weekly byday same week", "FREQ=WEEKLY;BYDAY=MO,TH", at(2026, 3, 5, 9), at(2026, 3, 9, 9), at(2026, 3, 12, 9)},
+		{"biweekly", "FREQ=WEEKLY;INTERVAL=2", at(2026, 3, 2, 9), time.Time{}, at(2026, 3, 16, 9)},
+		{"monthly skips short months", "FREQ=MONTHLY;BYMONTHDAY=31", at(2026, 1, 31, 9), time.Time{}, at(2026, 3, 31, 9)},
+		{"monthly last day", "FREQ=MONTHLY;BYMONTHDAY=-1", at(2026, 1, 31, 9), time.Time{}, at(2026, 2, 28, 9)},
+		{"yearly leap day", "FREQ=YEARLY", at(2024, 2, 29, 9), time.Time{}, at(2028, 2, 29, 9)},
+		// UNTIL 为 UTC 01:00，即本地 09:00，正好等于下一次
+		{"until inclusive", "FREQ=DAILY;UNTIL=20260302T010000Z", at(2026, 3, 1, 9), time.Time{}, at(2026, 3, 2, 9)},
+		{"until reached", "FREQ=DAILY;UNTIL=20260302T010000Z", at(2026, 3, 1, 9), at(2026, 3, 2, 9), time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := parseRRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after := tt.after
+			if after.IsZero() {
+				after = tt.anchor
+			}
+			got, ok := r.next(tt.anchor, after)
+			if ok != !tt.want.IsZero() || ok && !got.Equal(tt.want) {
+				t.Errorf("next = %v, %v; want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepeatSeriesEnds(t *testing.T) {
+	withLocal(t)
+	due := time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name      string
+		repeat    string
+		instances int
+	}{
+		{"count", "FREQ=DAILY;COUNT=3", 3},
+		// 截止时间在一小时前，第一次完成后没有下一次
+		{"until", "FREQ=DAILY;UNTIL=" + icsTime(due), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pp := NewWorkPlan(WorkPlanConfig{}).NewPersonalPlan()
+			todo, err := pp.AddTODO("water plants", TODOFields{Due: &due, Repeat: &tt.repeat})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 10; i++ {
+				res, err := pp.SetTODODone(todo.Id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				next := res.Next
+				if next == nil {
+					break
+				}
+				if next.Due <= todo.Due {
+					t.Fatalf("next due %d not after %d", next.Due, todo.Due)
+				}
+				todo = *next
+			}
+
+			history, err := pp.History(todo.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) != tt.instances {
+				t.Fatalf("instances = %d, want %d", len(history), tt.instances)
+			}
+			for _, h := range history {
+				if !h.Done {
+					t.Errorf("instance %d not done", h.Id)
+				}
+			}
+		})
+	}
+}
+
+func TestRepeatNextInstance(t *testing.T) {
+	withLocal(t)
+	due := time.Now().Add(time.Hour).Unix()
+	daily := "FREQ=DAILY"
+
+	tests := []struct {
+		name     string
+		maxTODOs int
+		// parent 为 true 时重复任务是父任务，通过完成唯一的子任务自动完成
+		parent  bool
+		spawned bool
+	}{
+		{name: "manual", spawned: true},
+		{name: "manual at limit", maxTODOs: 1},
+		{name: "auto done parent", parent: true, spawned: true},
+		{name: "auto done parent at limit", parent: true, maxTODOs: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pp := NewWorkPlan(WorkPlanConfig{MaxTODOs: tt.maxTODOs}).NewPersonalPlan()
+			pp.SetAutoDone(true)
+			todo, err := pp.AddTODO("standup", TODOFields{Due: &due, Repeat: &daily})
+			if err != nil {
+				t.Fatal(err)
+			}
+			toggle := todo.Id
+			if tt.parent {
+				child, err := pp.AddTODO("write notes", TODOFields{ParentID: &todo.Id})
+				if err != nil {
+					t.Fatal(err)
+				}
+				toggle = child.Id
+			}
+
+			res, err := pp.SetTODODone(toggle)
+			if err != nil {
+				t.Fatalf("completion rejected: %v", err)
+			}
+
+			history, err := pp.History(todo.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !history[0].Done {
+				t.Error("recurring todo not completed")
+			}
+			if got := len(history) == 2; got != tt.spawned {
+				t.Fatalf("next instance spawned = %v, want %v", got, tt.spawned)
+			}
+			if tt.spawned {
+				if next := history[1]; next.Done || next.Due <= due {
+					t.Errorf("next instance = %+v", next)
+				}
+			} else if len(res.Skipped) != 1 || res.Skipped[0] != todo.Id {
+				t.Errorf("skipped = %v, want [%d]", res.Skipped, todo.Id)
+			}
+			if manual := !tt.parent && tt.spawned; (res.Next != nil) != manual {
+				t.Errorf("next = %+v", res.Next)
+			}
+		})
+	}
+}
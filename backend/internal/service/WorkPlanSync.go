@@ -0,0 +1,87 @@
+// WorkPlan 实时同步：每次修改都以带类型的事件推送给所有连接中的客户端
+package service
+
+const (
+	// PlanEventSnapshot 在客户端连接时推送一次完整列表
+	PlanEventSnapshot = "snapshot"
+	PlanEventAdd      = "add"
+	PlanEventEdit     = "edit"
+	PlanEventDelete   = "delete"
+	PlanEventDone     = "done"
+	PlanEventMove     = "move"
+	PlanEventSettings = "settings"
+	PlanEventMembers  = "members"
+	// PlanEventTimer 在计时开始或停止时推送
+	PlanEventTimer = "timer"
+)
+
+// PlanEvent 为推送给客户端的事件
+type PlanEvent struct {
+	Type string `json:"type"`
+	// TODOs 为新增或变化后的 TODO，包括因 AutoDone 被自动更新的父任务及其下一次实例；snapshot 时为完整列表
+	TODOs []TODO `json:"todos,omitempty"`
+	// IDs 为 delete 时被删除的 ID（含子任务）
+	IDs []int `json:"ids,omitempty"`
+	// Order 为 move 之后全部 TODO 的 ID 顺序
+	Order    []int `json:"order,omitempty"`
+	AutoDone *bool `json:"auto_done,omitempty"`
+	// Members 为 members 事件与 snapshot 中的完整成员列表
+	Members []Member `json:"members,omitempty"`
+	// Sessions 为开始或停止的计时记录
+	Sessions []TimeSession `json:"sessions,omitempty"`
+}
+
+// AddClient 注册客户端，并先推送一次当前列表
+func (pp *PersonalPlan) AddClient() chan PlanEvent {
+	ch := make(chan PlanEvent, 16)
+
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	if pp.clients == nil {
+		pp.clients = make(map[chan PlanEvent]struct{})
+	}
+	pp.clients[ch] = struct{}{}
+	// 持锁写入，保证快照排在之后的所有事件前面；此时缓冲区为空，不会阻塞
+	autoDone := pp.AutoDone
+	ch <- PlanEvent{
+		Type:     PlanEventSnapshot,
+		TODOs:    pp.sorted(),
+		AutoDone: &autoDone,
+		Members:  append([]Member{}, pp.members...),
+	}
+	return ch
+}
+
+// RemoveClient 注销客户端，并关闭通道
+func (pp *PersonalPlan) RemoveClient(ch chan PlanEvent) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	if _, ok := pp.clients[ch]; ok {
+		delete(pp.clients, ch)
+		close(ch)
+	}
+}
+
+// broadcast 推送事件；缓冲区已满的客户端会错过增量而无法保持同步，
+// 直接断开，由客户端重连后重新获取快照。调用方需持有写锁
+func (pp *PersonalPlan) broadcast(ev PlanEvent) {
+	for ch := range pp.clients {
+		select {
+		case ch <- ev:
+		default:
+			delete(pp.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// order 返回全部 TODO 的 ID 顺序；调用方需持有锁
+func (pp *PersonalPlan) order() []int {
+	ids := make([]int, len(pp.TODOs))
+	for i, t := range pp.TODOs {
+		ids[i] = t.Id
+	}
+	return ids
+}
@@ -0,0 +1,174 @@
+// WorkPlan 共享：编辑 hash 与只读 hash 分开发放，成员列表与 TODO 负责人
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxPlanMembers    = 100
+	MaxMemberNameSize = 64
+)
+
+var (
+	ErrPlanReadOnly     = errors.New("plan is read-only with this hash")
+	ErrMemberNotFound   = errors.New("member not found")
+	ErrMemberName       = fmt.Errorf("member name must be 1-%d characters", MaxMemberNameSize)
+	ErrMemberExists     = errors.New("member name already exists")
+	ErrMemberTooMany    = fmt.Errorf("at most %d members", MaxPlanMembers)
+	ErrTODOAssigneeGone = errors.New("assignee is not a member of the plan")
+)
+
+// Member 为计划成员，TODO 通过 ID 指定负责人，改名不影响已有的分配
+type Member struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	JoinedAt int64  `json:"joined_at"`
+}
+
+// ViewPlan 按编辑 hash 或只读 hash 查找计划，editable 表示是否为编辑 hash
+func (wp *WorkPlan) ViewPlan(hash string) (pp *PersonalPlan, editable bool) {
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
+
+	if pp := wp.Plan[hash]; pp != nil {
+		return pp, true
+	}
+	return wp.views[hash], false
+}
+
+// EditPlan 只接受编辑 hash，使用只读 hash 时返回 ErrPlanReadOnly
+func (wp *WorkPlan) EditPlan(hash string) (*PersonalPlan, error) {
+	pp, editable := wp.ViewPlan(hash)
+	switch {
+	case pp == nil:
+		return nil, ErrPlanNotFound
+	case !editable:
+		return nil, ErrPlanReadOnly
+	}
+	return pp, nil
+}
+
+// Members 返回成员列表副本
+func (pp *PersonalPlan) Members() []Member {
+	pp.mu.RLock()
+	defer pp.mu.RUnlock()
+	return append([]Member{}, pp.members...)
+}
+
+// AddMember 添加成员，名字不区分大小写不能重复
+func (pp *PersonalPlan) AddMember(name string) (Member, error) {
+	name, err := memberName(name)
+	if err != nil {
+		return Member{}, err
+	}
+
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	if len(pp.members) >= MaxPlanMembers {
+		return Member{}, ErrMemberTooMany
+	}
+	if pp.memberNamed(name, 0) {
+		return Member{}, ErrMemberExists
+	}
+
+	pp.lastMemberID++
+	m := Member{ID: pp.lastMemberID, Name: name, JoinedAt: time.Now().Unix()}
+	pp.members = append(pp.members, m)
+	pp.broadcast(PlanEvent{Type: PlanEventMembers, Members: append([]Member{}, pp.members...)})
+	return m, nil
+}
+
+func (pp *PersonalPlan) RenameMember(id int, name string) error {
+	name, err := memberName(name)
+	if err != nil {
+		return err
+	}
+
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	i := pp.findMember(id)
+	if i < 0 {
+		return ErrMemberNotFound
+	}
+	if pp.memberNamed(name, id) {
+		return ErrMemberExists
+	}
+	pp.members[i].Name = name
+	pp.broadcast(PlanEvent{Type: PlanEventMembers, Members: append([]Member{}, pp.members...)})
+	return nil
+}
+
+// RemoveMember 删除成员，并取消其负责的 TODO 的分配
+func (pp *PersonalPlan) RemoveMember(id int) error {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	i := pp.findMember(id)
+	if i < 0 {
+		return ErrMemberNotFound
+	}
+	name := pp.members[i].Name
+	pp.members = append(pp.members[:i], pp.members[i+1:]...)
+
+	before := slices.Clone(pp.TODOs)
+	var changed []TODO
+	var ids []int
+	for j := range pp.TODOs {
+		if pp.TODOs[j].Assignee == id {
+			pp.TODOs[j].Assignee = 0
+			changed = append(changed, pp.TODOs[j])
+			ids = append(ids, pp.TODOs[j].Id)
+		}
+	}
+	// 取消分配单独作为一步撤销，否则撤销更早的操作时恢复的快照会把它一并回退；
+	// 成员本身不在撤销范围内，撤销这一步时已删除成员的分配仍会被清除
+	if len(ids) > 0 {
+		pp.record(ActivityUnassign, before, ids, name)
+	}
+	pp.broadcast(PlanEvent{Type: PlanEventMembers, Members: append([]Member{}, pp.members...), TODOs: changed})
+	return nil
+}
+
+func memberName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxMemberNameSize {
+		return "", ErrMemberName
+	}
+	return name, nil
+}
+
+// memberNamed 判断除 except 以外是否已有同名成员；调用方需持有锁
+func (pp *PersonalPlan) memberNamed(name string, except int) bool {
+	for _, m := range pp.members {
+		if m.ID != except && strings.EqualFold(m.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// findMember 返回成员下标，不存在时返回 -1；调用方需持有锁
+func (pp *PersonalPlan) findMember(id int) int {
+	for i, m := range pp.members {
+		if m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkAssignee 校验负责人为计划成员，0 表示不分配；调用方需持有锁
+func (pp *PersonalPlan) checkAssignee(f TODOFields) error {
+	if f.Assignee == nil || *f.Assignee == 0 || pp.findMember(*f.Assignee) >= 0 {
+		return nil
+	}
+	return ErrTODOAssigneeGone
+}
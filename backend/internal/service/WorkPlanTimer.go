@@ -0,0 +1,376 @@
+// WorkPlan 计时：TODO 上的开始 / 停止计时与番茄钟，按 TODO、标签、日期汇总用时
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	// 每个计划保存的计时记录上限，超出后清理最早的已结束记录
+	MaxTimeSessions = 10000
+	// 普通计时最长持续时间，忘记停止的计时到时自动结束
+	MaxTimerDuration = 12 * 60 * 60
+
+	DefaultPomodoroWork   = 25 * 60
+	DefaultPomodoroBreak  = 5 * 60
+	DefaultPomodoroRounds = 4
+
+	PomodoroPhaseWork  = "work"
+	PomodoroPhaseBreak = "break"
+)
+
+var (
+	ErrTimerNotRunning = errors.New("no running timer")
+	ErrTimerTooMany    = fmt.Errorf("at most %d time sessions", MaxTimeSessions)
+	ErrPomodoro        = errors.New("pomodoro work must be 60-7200 seconds, break 60-3600 seconds and rounds 1-12")
+	ErrReportRange     = errors.New("report range is invalid")
+)
+
+// Pomodoro 为番茄钟设置，时间单位为秒；零值字段使用默认值
+type Pomodoro struct {
+	Work   int64 `json:"work"`
+	Break  int64 `json:"break"`
+	Rounds int   `json:"rounds"`
+}
+
+// TimeSession 为一次计时记录；番茄钟只统计工作阶段的时间
+type TimeSession struct {
+	ID     int `json:"id"`
+	TODOID int `json:"todo_id"`
+	// Member 为计时的成员，0 表示匿名
+	Member int `json:"member,omitempty"`
+	// Content / Tags 为开始时 TODO 的快照，TODO 删除后报表仍可使用，直到记录数达到上限时被优先清理
+	Content string   `json:"content"`
+	Tags    []string `json:"tags,omitempty"`
+	Start   int64    `json:"start"`
+	// End 为 0 表示仍在计时
+	End      int64     `json:"end,omitempty"`
+	Pomodoro *Pomodoro `json:"pomodoro,omitempty"`
+	// Seconds 为已计入的时间，计时中的记录按当前时间计算
+	Seconds int64 `json:"seconds"`
+}
+
+// TimerStatus 为计时中的记录及番茄钟当前阶段
+type TimerStatus struct {
+	TimeSession
+	Phase    string `json:"phase,omitempty"`
+	Round    int    `json:"round,omitempty"`
+	PhaseEnd int64  `json:"phase_end,omitempty"`
+}
+
+// TimeTotal 为报表中的一项
+type TimeTotal struct {
+	TODOID  int    `json:"todo_id,omitempty"`
+	Content string `json:"content,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+	Day     string `json:"day,omitempty"`
+	Seconds int64  `json:"seconds"`
+}
+
+// TimeReport 汇总 [From, To) 内的用时；同一段时间会计入 TODO 的每个标签
+type TimeReport struct {
+	From   int64       `json:"from"`
+	To     int64       `json:"to"`
+	Total  int64       `json:"total"`
+	ByTODO []TimeTotal `json:"by_todo"`
+	ByTag  []TimeTotal `json:"by_tag"`
+	ByDay  []TimeTotal `json:"by_day"`
+}
+
+func (p *Pomodoro) normalize() error {
+	if p.Work == 0 {
+		p.Work = DefaultPomodoroWork
+	}
+	if p.Break == 0 {
+		p.Break = DefaultPomodoroBreak
+	}
+	if p.Rounds == 0 {
+		p.Rounds = DefaultPomodoroRounds
+	}
+	if p.Work < 60 || p.Work > 120*60 || p.Break < 60 || p.Break > 60*60 || p.Rounds < 1 || p.Rounds > 12 {
+		return ErrPomodoro
+	}
+	return nil
+}
+
+// limit 返回计时自动结束的时间：番茄钟在最后一轮工作结束时结束
+func (s *TimeSession) limit() int64 {
+	if p := s.Pomodoro; p != nil {
+		return s.Start + int64(p.Rounds)*(p.Work+p.Break) - p.Break
+	}
+	return s.Start + MaxTimerDuration
+}
+
+func (s *TimeSession) running(now int64) bool {
+	return s.End == 0 && now < s.limit()
+}
+
+// endAt 返回结束时间，计时中的记录返回 now
+func (s *TimeSession) endAt(now int64) int64 {
+	if s.End != 0 {
+		return s.End
+	}
+	return min(now, s.limit())
+}
+
+// work 返回计入用时的时间段
+func (s *TimeSession) work(now int64) [][2]int64 {
+	end := s.endAt(now)
+	p := s.Pomodoro
+	if p == nil {
+		return [][2]int64{{s.Start, end}}
+	}
+	var spans [][2]int64
+	for t := s.Start; t < end; t += p.Work + p.Break {
+		spans = append(spans, [2]int64{t, min(t+p.Work, end)})
+	}
+	return spans
+}
+
+func (s *TimeSession) seconds(now int64) int64 {
+	var total int64
+	for _, w := range s.work(now) {
+		total += w[1] - w[0]
+	}
+	return total
+}
+
+// status 计算番茄钟的当前阶段
+func (s *TimeSession) status(now int64) TimerStatus {
+	st := TimerStatus{TimeSession: *s}
+	st.Seconds = s.seconds(now)
+	if p := s.Pomodoro; p != nil {
+		cycle := p.Work + p.Break
+		elapsed := now - s.Start
+		st.Round = int(elapsed/cycle) + 1
+		start := s.Start + int64(st.Round-1)*cycle
+		if elapsed%cycle < p.Work {
+			st.Phase, st.PhaseEnd = PomodoroPhaseWork, start+p.Work
+		} else {
+			st.Phase, st.PhaseEnd = PomodoroPhaseBreak, start+cycle
+		}
+	}
+	return st
+}
+
+// settleTimers 将已到时的计时标记为结束；调用方需持有写锁
+func (pp *PersonalPlan) settleTimers(now int64) {
+	for i := range pp.sessions {
+		s := &pp.sessions[i]
+		if s.End == 0 && !s.running(now) {
+			s.End = s.limit()
+			s.Seconds = s.seconds(now)
+		}
+	}
+}
+
+// pruneSessions 在记录数达到上限时腾出一条记录的空间：先删除 TODO 已不存在的已结束记录，
+// 仍不够时删除最早的已结束记录；全部都在计时中时返回 false。调用方需持有写锁并已调用 settleTimers
+func (pp *PersonalPlan) pruneSessions() bool {
+	if len(pp.sessions) < MaxTimeSessions {
+		return true
+	}
+
+	ids := make(map[int]bool, len(pp.TODOs))
+	for _, t := range pp.TODOs {
+		ids[t.Id] = true
+	}
+	pp.sessions = slices.DeleteFunc(pp.sessions, func(s TimeSession) bool {
+		return s.End != 0 && !ids[s.TODOID]
+	})
+
+	// 记录按开始时间追加，从头删除即最早的记录
+	for i := 0; len(pp.sessions) >= MaxTimeSessions && i < len(pp.sessions); {
+		if pp.sessions[i].End != 0 {
+			pp.sessions = slices.Delete(pp.sessions, i, i+1)
+		} else {
+			i++
+		}
+	}
+	return len(pp.sessions) < MaxTimeSessions
+}
+
+// runningTimer 返回成员计时中的记录下标，todoID 不为 0 时还需是该 TODO；调用方需持有锁
+func (pp *PersonalPlan) runningTimer(member, todoID int, now int64) int {
+	for i, s := range pp.sessions {
+		if s.Member == member && (todoID == 0 || s.TODOID == todoID) && s.running(now) {
+			return i
+		}
+	}
+	return -1
+}
+
+// StartTimer 开始计时，pomodoro 为 nil 时为普通计时；同一成员同时只能有一个计时，之前的计时会被停止并返回
+func (pp *PersonalPlan) StartTimer(todoID, member int, pomodoro *Pomodoro) (started TimeSession, stopped *TimeSession, err error) {
+	if pomodoro != nil {
+		if err := pomodoro.normalize(); err != nil {
+			return TimeSession{}, nil, err
+		}
+	}
+
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	i := pp.find(todoID)
+	if i < 0 {
+		return TimeSession{}, nil, ErrTODONotFound
+	}
+	if member != 0 && pp.findMember(member) < 0 {
+		return TimeSession{}, nil, ErrMemberNotFound
+	}
+	// 无论是否停止旧计时都会追加一条记录，先腾出空间，拒绝时不改动正在进行的计时
+	now := time.Now().Unix()
+	pp.settleTimers(now)
+	if !pp.pruneSessions() {
+		return TimeSession{}, nil, ErrTimerTooMany
+	}
+
+	if j := pp.runningTimer(member, 0, now); j >= 0 {
+		pp.stopTimer(j, now)
+		s := pp.sessions[j]
+		stopped = &s
+	}
+
+	t := pp.TODOs[i]
+	pp.lastSessionID++
+	started = TimeSession{
+		ID:       pp.lastSessionID,
+		TODOID:   todoID,
+		Member:   member,
+		Content:  t.Content,
+		Tags:     slices.Clone(t.Tags),
+		Start:    now,
+		Pomodoro: pomodoro,
+	}
+	pp.sessions = append(pp.sessions, started)
+
+	ev := PlanEvent{Type: PlanEventTimer, Sessions: []TimeSession{started}}
+	if stopped != nil {
+		ev.Sessions = append(ev.Sessions, *stopped)
+	}
+	pp.broadcast(ev)
+	return started, stopped, nil
+}
+
+// StopTimer 停止成员的计时，todoID 为 0 时停止该成员任意 TODO 上的计时
+func (pp *PersonalPlan) StopTimer(todoID, member int) (TimeSession, error) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	now := time.Now().Unix()
+	pp.settleTimers(now)
+	i := pp.runningTimer(member, todoID, now)
+	if i < 0 {
+		return TimeSession{}, ErrTimerNotRunning
+	}
+	pp.stopTimer(i, now)
+	pp.broadcast(PlanEvent{Type: PlanEventTimer, Sessions: []TimeSession{pp.sessions[i]}})
+	return pp.sessions[i], nil
+}
+
+// stopTimer 调用方需持有写锁
+func (pp *PersonalPlan) stopTimer(i int, now int64) {
+	s := &pp.sessions[i]
+	s.End = now
+	s.Seconds = s.seconds(now)
+}
+
+// Timers 返回计时中的记录
+func (pp *PersonalPlan) Timers() []TimerStatus {
+	pp.mu.RLock()
+	defer pp.mu.RUnlock()
+
+	now := time.Now().Unix()
+	list := []TimerStatus{}
+	for _, s := range pp.sessions {
+		if s.running(now) {
+			list = append(list, s.status(now))
+		}
+	}
+	return list
+}
+
+// TimeReport 汇总 [from, to) 内的用时，按 loc 的自然日分组；member 不为 0 时只统计该成员
+func (pp *PersonalPlan) TimeReport(from, to int64, member int, loc *time.Location) (*TimeReport, error) {
+	now := time.Now().Unix()
+	if to == 0 {
+		to = now
+	}
+	if from < 0 || from >= to {
+		return nil, ErrReportRange
+	}
+
+	pp.mu.RLock()
+	defer pp.mu.RUnlock()
+
+	// TODO 仍存在时使用当前的内容与标签
+	current := make(map[int]TODO, len(pp.TODOs))
+	for _, t := range pp.TODOs {
+		current[t.Id] = t
+	}
+
+	r := &TimeReport{From: from, To: to}
+	byTODO := make(map[int]*TimeTotal)
+	byTag := make(map[string]int64)
+	byDay := make(map[string]int64)
+	for i := range pp.sessions {
+		s := &pp.sessions[i]
+		if member != 0 && s.Member != member {
+			continue
+		}
+		content, tags := s.Content, s.Tags
+		if t, ok := current[s.TODOID]; ok {
+			content, tags = t.Content, t.Tags
+		}
+
+		for _, w := range s.work(now) {
+			start, end := max(w[0], from), min(w[1], to)
+			if start >= end {
+				continue
+			}
+			n := end - start
+			r.Total += n
+			if byTODO[s.TODOID] == nil {
+				byTODO[s.TODOID] = &TimeTotal{TODOID: s.TODOID, Content: content}
+			}
+			byTODO[s.TODOID].Seconds += n
+			for _, tag := range tags {
+				byTag[tag] += n
+			}
+			// 跨天的时间段按自然日拆分
+			for start < end {
+				day := time.Unix(start, 0).In(loc)
+				next := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc).Unix()
+				byDay[day.Format(time.DateOnly)] += min(end, next) - start
+				start = next
+			}
+		}
+	}
+
+	r.ByTODO = []TimeTotal{}
+	for _, t := range byTODO {
+		r.ByTODO = append(r.ByTODO, *t)
+	}
+	sort.Slice(r.ByTODO, func(i, j int) bool {
+		a, b := r.ByTODO[i], r.ByTODO[j]
+		return a.Seconds > b.Seconds || a.Seconds == b.Seconds && a.TODOID < b.TODOID
+	})
+	r.ByTag = []TimeTotal{}
+	for tag, n := range byTag {
+		r.ByTag = append(r.ByTag, TimeTotal{Tag: tag, Seconds: n})
+	}
+	sort.Slice(r.ByTag, func(i, j int) bool {
+		a, b := r.ByTag[i], r.ByTag[j]
+		return a.Seconds > b.Seconds || a.Seconds == b.Seconds && a.Tag < b.Tag
+	})
+	r.ByDay = []TimeTotal{}
+	for day, n := range byDay {
+		r.ByDay = append(r.ByDay, TimeTotal{Day: day, Seconds: n})
+	}
+	sort.Slice(r.ByDay, func(i, j int) bool { return r.ByDay[i].Day < r.ByDay[j].Day })
+	return r, nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStartTimerLimit(t *testing.T) {
+	const deletedTODO = 999
+
+	tests := []struct {
+		name     string
+		sessions int
+		running  bool // 最后一条记录计时中，开始新计时会先停止它
+		deleted  int  // 最后若干条已结束记录属于已删除的 TODO
+		allBusy  bool // 所有记录都在计时中
+		// 开始后被清理的记录 ID
+		pruned  []int
+		wantErr error
+	}{
+		{name: "below limit", sessions: MaxTimeSessions - 1},
+		{name: "below limit with running timer", sessions: MaxTimeSessions - 1, running: true},
+		{name: "at limit drops oldest", sessions: MaxTimeSessions, pruned: []int{1}},
+		{name: "at limit with running timer", sessions: MaxTimeSessions, running: true, pruned: []int{1}},
+		// 已删除 TODO 的记录即使较新也先被清理
+		{name: "at limit drops deleted todos first", sessions: MaxTimeSessions, deleted: 2, pruned: []int{MaxTimeSessions - 1, MaxTimeSessions}},
+		{name: "all running", sessions: MaxTimeSessions, allBusy: true, wantErr: ErrTimerTooMany},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pp := NewWorkPlan(WorkPlanConfig{}).NewPersonalPlan()
+			todo, err := pp.AddTODO("focus", TODOFields{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Now().Unix()
+			for i := 0; i < tt.sessions; i++ {
+				s := TimeSession{ID: i + 1, TODOID: todo.Id, Member: i + 1, Start: now - 60, End: now - 30}
+				if tt.allBusy {
+					s.End = 0
+				}
+				if i >= tt.sessions-tt.deleted {
+					s.TODOID = deletedTODO
+				}
+				pp.sessions = append(pp.sessions, s)
+			}
+			if tt.running {
+				last := &pp.sessions[len(pp.sessions)-1]
+				last.End, last.Member = 0, 0
+			}
+			pp.lastSessionID = tt.sessions
+
+			_, stopped, err := pp.StartTimer(todo.Id, 0, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if n := len(pp.sessions); n > MaxTimeSessions {
+				t.Errorf("sessions = %d, exceeds %d", n, MaxTimeSessions)
+			}
+			if got := stopped != nil; got != (tt.running && tt.wantErr == nil) {
+				t.Errorf("stopped = %v", stopped)
+			}
+			if tt.wantErr != nil {
+				if len(pp.sessions) != tt.sessions {
+					t.Errorf("rejected start changed sessions to %d", len(pp.sessions))
+				}
+				return
+			}
+
+			kept := make(map[int]bool)
+			for _, s := range pp.sessions {
+				kept[s.ID] = true
+			}
+			for _, id := range tt.pruned {
+				if kept[id] {
+					t.Errorf("session %d should be pruned", id)
+				}
+			}
+			if want := tt.sessions - len(tt.pruned) + 1; len(pp.sessions) != want {
+				t.Errorf("sessions = %d, want %d", len(pp.sessions), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// WorkPlan 子任务：ParentID 组成任意深度的树，支持完成度汇总与子任务全部完成时自动完成父任务
+package service
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var ErrTODOParent = errors.New("invalid parent: it must exist and not be the TODO itself or its descendant")
+
+// TODONode 为树中的一个节点
+type TODONode struct {
+	TODO
+	// Progress 为子树中已完成叶子任务的百分比（0-100），已完成的节点为 100
+	Progress int         `json:"progress"`
+	Children []*TODONode `json:"children"`
+}
+
+// SetAutoDone 设置子任务全部完成时是否自动完成父任务；开启时立即按当前状态汇总一次
+func (pp *PersonalPlan) SetAutoDone(on bool) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	pp.AutoDone = on
+	before := slices.Clone(pp.TODOs)
+	var changed []TODO
+	if on {
+		// 汇总时可能插入重复任务的下一次实例，遍历开始时的副本
+		for _, t := range before {
+			changed = append(changed, pp.rollUp(t.ParentID)...)
+		}
+	}
+	// 只有自动完成了父任务时才需要能撤销
+	if len(changed) > 0 {
+		ids := make([]int, len(changed))
+		for i, t := range changed {
+			ids[i] = t.Id
+		}
+		pp.record(ActivitySettings, before, ids, "")
+	}
+	pp.broadcast(PlanEvent{Type: PlanEventSettings, TODOs: changed, AutoDone: &on})
+}
+
+func (pp *PersonalPlan) AutoDoneEnabled() bool {
+	pp.mu.RLock()
+	defer pp.mu.RUnlock()
+	return pp.AutoDone
+}
+
+// Tree 将 todos 组织为树，父任务不在 todos 中的节点作为根；完成度按计划中的完整子树计算
+func (pp *PersonalPlan) Tree(todos []TODO) []*TODONode {
+	pp.mu.RLock()
+	progress := progressOf(pp.TODOs)
+	pp.mu.RUnlock()
+
+	nodes := make(map[int]*TODONode, len(todos))
+	for _, t := range todos {
+		nodes[t.Id] = &TODONode{TODO: t, Progress: progress[t.Id], Children: []*TODONode{}}
+	}
+
+	roots := []*TODONode{}
+	for _, t := range todos {
+		n := nodes[t.Id]
+		if p, ok := nodes[t.ParentID]; ok && t.ParentID != 0 {
+			p.Children = append(p.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	return roots
+}
+
+// progressOf 计算每个 TODO 子树的完成百分比
+func progressOf(all []TODO) map[int]int {
+	kids := make(map[int][]TODO)
+	for _, t := range all {
+		if t.ParentID != 0 {
+			kids[t.ParentID] = append(kids[t.ParentID], t)
+		}
+	}
+
+	progress := make(map[int]int, len(all))
+	var walk func(t TODO) (done, total int)
+	walk = func(t TODO) (done, total int) {
+		for _, k := range kids[t.Id] {
+			d, n := walk(k)
+			done, total = done+d, total+n
+		}
+		if total == 0 {
+			total = 1
+			if t.Done {
+				done = 1
+			}
+		} else if t.Done {
+			done = total
+		}
+		progress[t.Id] = done * 100 / total
+		return done, total
+	}
+	for _, t := range all {
+		if t.ParentID == 0 {
+			walk(t)
+		}
+	}
+	return progress
+}
+
+// checkParent 校验 parentID 可以作为 id 的父任务（id 为 0 表示新建）；调用方需持有锁
+func (pp *PersonalPlan) checkParent(id, parentID int) error {
+	if parentID == 0 {
+		return nil
+	}
+	if parentID == id || pp.find(parentID) < 0 {
+		return ErrTODOParent
+	}
+	if id == 0 {
+		return nil
+	}
+	// 沿父链向上，不能经过自己
+	for p := parentID; p != 0; {
+		i := pp.find(p)
+		if i < 0 {
+			break
+		}
+		if p = pp.TODOs[i].ParentID; p == id {
+			return ErrTODOParent
+		}
+	}
+	return nil
+}
+
+// subtree 返回 id 及其全部后代的 ID；调用方需持有锁
+func (pp *PersonalPlan) subtree(id int) map[int]bool {
+	ids := map[int]bool{id: true}
+	for changed := true; changed; {
+		changed = false
+		for _, t := range pp.TODOs {
+			if !ids[t.Id] && ids[t.ParentID] {
+				ids[t.Id] = true
+				changed = true
+			}
+		}
+	}
+	return ids
+}
+
+// rollUp 在开启 AutoDone 时自下而上同步父任务状态：子任务全部完成则完成，否则重新打开；
+// 返回状态发生变化的父任务，以及被自动完成的重复父任务的下一次实例，调用方需持有写锁
+func (pp *PersonalPlan) rollUp(parentID int) []TODO {
+	changed, _ := pp.rollUpSkips(parentID)
+	return changed
+}
+
+// rollUpSkips 与 rollUp 相同，另外返回因达到 TODO 上限而没有生成下一次实例的重复父任务
+func (pp *PersonalPlan) rollUpSkips(parentID int) (changed []TODO, skipped []int) {
+	if !pp.AutoDone {
+		return nil, nil
+	}
+	now := time.Now()
+	for parentID != 0 {
+		i := pp.find(parentID)
+		if i < 0 {
+			return changed, skipped
+		}
+
+		hasKids, allDone := false, true
+		for _, t := range pp.TODOs {
+			if t.ParentID == parentID {
+				hasKids = true
+				allDone = allDone && t.Done
+			}
+		}
+		p := &pp.TODOs[i]
+		if !hasKids || p.Done == allDone {
+			return changed, skipped
+		}
+		p.Done = allDone
+		if allDone {
+			p.CompletedAt = now.Unix()
+		} else {
+			p.CompletedAt = 0
+		}
+		changed = append(changed, *p)
+		parentID = p.ParentID
+
+		// 自动完成的重复任务与手动完成一样生成下一次实例；插入后 p 不再有效
+		if allDone && pp.TODOs[i].Repeat != "" {
+			next, skip := pp.spawnNext(i, now)
+			if next != nil {
+				changed = append(changed, *next)
+			}
+			if skip {
+				skipped = append(skipped, pp.TODOs[i].Id)
+			}
+		}
+	}
+	return changed, skipped
+}
@@ -0,0 +1,74 @@
+// src/api/codecrypto.ts
+// CodeShare 端到端加密：AES-GCM 在浏览器内完成，密钥只放在链接的 # 片段中，不会发送给服务器
+
+export const CIPHER_ALGORITHM = "AES-GCM";
+
+export interface EncryptedSnippet {
+  ciphertext: string; // base64
+  nonce: string; // base64
+  key: string; // base64url，拼在分享链接的 # 后面
+}
+
+function toBase64(buf: ArrayBuffer | Uint8Array): string {
+  const bytes = buf instanceof Uint8Array ? buf : new Uint8Array(buf);
+  let bin = "";
+  bytes.forEach((b) => (bin += String.fromCharCode(b)));
+  return btoa(bin);
+}
+
+function fromBase64(s: string): Uint8Array {
+  const bin = atob(s);
+  const bytes = new Uint8Array(bin.length);
+  for (let i = 0; i < bin.length; i++) bytes[i] = bin.charCodeAt(i);
+  return bytes;
+}
+
+function toBase64Url(buf: ArrayBuffer): string {
+  return toBase64(buf).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function fromBase64Url(s: string): Uint8Array {
+  const b64 = s.replace(/-/g, "+").replace(/_/g, "/");
+  return fromBase64(b64 + "=".repeat((4 - (b64.length % 4)) % 4));
+}
+
+export async function encryptSnippet(plaintext: string): Promise<EncryptedSnippet> {
+  const key = await crypto.subtle.generateKey(
+    { name: CIPHER_ALGORITHM, length: 256 },
+    true,
+    ["encrypt", "decrypt"],
+  );
+  const nonce = crypto.getRandomValues(new Uint8Array(12));
+  const data = await crypto.subtle.encrypt(
+    { name: CIPHER_ALGORITHM, iv: nonce },
+    key,
+    new TextEncoder().encode(plaintext),
+  );
+  const raw = await crypto.subtle.exportKey("raw", key);
+
+  return {
+    ciphertext: toBase64(data),
+    nonce: toBase64(nonce),
+    key: toBase64Url(raw),
+  };
+}
+
+export async function decryptSnippet(
+  ciphertext: string,
+  nonce: string,
+  key: string,
+): Promise<string> {
+  const cryptoKey = await crypto.subtle.importKey(
+    "raw",
+    fromBase64Url(key),
+    CIPHER_ALGORITHM,
+    false,
+    ["decrypt"],
+  );
+  const data = await crypto.subtle.decrypt(
+    { name: CIPHER_ALGORITHM, iv: fromBase64(nonce) },
+    cryptoKey,
+    fromBase64(ciphertext),
+  );
+  return new TextDecoder().decode(data);
+}
@@ -1,16 +1,26 @@
 // src/api/codeshare.ts
 import http from "./http";
 
+export interface CodeEncryption {
+  algorithm: string;
+  nonce: string;
+}
+
 export interface UploadCodePayload {
   author: string;
   language: string;
   content: string;
-  destroy_time: number;
+  // 预设：10m / 1h / 1d / 1w，never 只在 /codeshare/ttl 返回时可用
+  expire?: string;
+  ttl?: number;
+  encryption?: CodeEncryption;
+  public?: boolean;
 }
 
 export interface UploadCodeResponse {
   hash: string;
   url?: string;
+  token?: string;
 }
 
 export function uploadCode(data: UploadCodePayload) {
@@ -18,7 +28,139 @@ export function uploadCode(data: UploadCodePayload) {
   return http.post<UploadCodeResponse>("/codeshare/upload", data);
 }
 
+export interface TTLPreset {
+  name: string;
+  ttl: number;
+}
+
+export interface TTLOptionsResponse {
+  policy: { min: number; max: number; default: number; max_pinned: number };
+  presets: TTLPreset[];
+}
+
+export function getTTLOptions() {
+  // 实际请求：<baseURL>/codeshare/ttl
+  return http.get<TTLOptionsResponse>("/codeshare/ttl");
+}
+
 export function getCodeByHash(hash: string) {
   // 实际请求：<baseURL>/codeshare/code/:hash
   return http.get(`/codeshare/code/${hash}`);
 }
+
+export interface CodeSummary {
+  hash: string;
+  author: string;
+  language: string;
+  files?: string[];
+  size: number;
+  preview?: string;
+  encrypted?: boolean;
+  revision: number;
+  views: number;
+  created_at: number;
+  destroy_time: number;
+}
+
+export interface CodeListQuery {
+  q?: string;
+  language?: string;
+  author?: string;
+  since?: number | string;
+  until?: number | string;
+  page?: number;
+  size?: number;
+}
+
+export interface CodeListResponse {
+  items: CodeSummary[];
+  total: number;
+  page: number;
+  size: number;
+}
+
+export function listCodes(params: CodeListQuery = {}) {
+  // 实际请求：<baseURL>/codeshare/list
+  return http.get<CodeListResponse>("/codeshare/list", { params });
+}
+
+export interface CodeComment {
+  id: number;
+  parent_id?: number;
+  revision: number;
+  file?: string;
+  start_line: number;
+  end_line: number;
+  author: string;
+  body: string;
+  resolved: boolean;
+  created_at: number;
+}
+
+export interface CommentThread extends CodeComment {
+  replies: CodeComment[];
+}
+
+export interface AddCommentPayload {
+  hash: string;
+  author: string;
+  body: string;
+  file?: string;
+  start_line?: number;
+  end_line?: number;
+  parent_id?: number;
+}
+
+export function listComments(hash: string) {
+  // 实际请求：<baseURL>/codeshare/comments/:hash
+  return http.get<{ comments: CommentThread[] }>(`/codeshare/comments/${hash}`);
+}
+
+export function addComment(data: AddCommentPayload) {
+  // 实际请求：<baseURL>/codeshare/comment
+  return http.post<CodeComment>("/codeshare/comment", data);
+}
+
+export function resolveComment(hash: string, id: number, resolved = true) {
+  // 实际请求：<baseURL>/codeshare/comment/resolve
+  return http.post<CodeComment>("/codeshare/comment/resolve", { hash, id, resolved });
+}
+
+// 订阅新评论与解决状态变化，返回的 EventSource 需在离开页面时关闭。
+// 处理过慢时服务端会断开连接，浏览器自动重连后调用 onOpen，调用方应重新获取评论列表
+export function streamComments(
+  hash: string,
+  onEvent: (type: "comment" | "resolved", comment: CodeComment) => void,
+  onOpen?: () => void,
+) {
+  const es = new EventSource(`${http.defaults.baseURL}/codeshare/comments/${hash}/stream`);
+  if (onOpen) es.onopen = onOpen;
+  for (const type of ["comment", "resolved"] as const) {
+    es.addEventListener(type, (e) => onEvent(type, JSON.parse((e as MessageEvent).data)));
+  }
+  return es;
+}
+
+// 管理 token 保存在本地，只有上传者的浏览器可以删除片段
+const tokenKey = (hash: string) => `codeshare-token:${hash}`;
+
+export function saveCodeToken(hash: string, token: string) {
+  localStorage.setItem(tokenKey(hash), token);
+}
+
+export function loadCodeToken(hash: string) {
+  return localStorage.getItem(tokenKey(hash));
+}
+
+export function extendCode(hash: string, token: string, expire: string) {
+  // 实际请求：<baseURL>/codeshare/extend
+  return http.post("/codeshare/extend", { hash, token, expire });
+}
+
+export function deleteCode(hash: string, token: string) {
+  // 实际请求：<baseURL>/codeshare/delete
+  return http.post("/codeshare/delete", { hash, token }).then((res) => {
+    localStorage.removeItem(tokenKey(hash));
+    return res;
+  });
+}
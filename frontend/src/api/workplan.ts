@@ -5,10 +5,70 @@ export interface TodoItem {
   id: number;
   content: string;
   done: boolean;
+  parent_id?: number;
+  // unix 秒
+  due?: number;
+  // 0 无 / 1 低 / 2 中 / 3 高
+  priority: number;
+  tags?: string[];
+  // 负责人的成员 ID
+  assignee?: number;
+  created_at: number;
+  completed_at?: number;
+  // 规范化后的 RRULE，同一重复系列共享 series_id
+  repeat?: string;
+  series_id?: number;
+}
+
+export interface PlanMember {
+  id: number;
+  name: string;
+  joined_at: number;
+}
+
+export interface TodoNode extends TodoItem {
+  // 子树中已完成叶子任务的百分比
+  progress: number;
+  children: TodoNode[];
+}
+
+export interface WorkPlanTodosResponse {
+  todos: TodoItem[];
+  tree: TodoNode[];
+  auto_done: boolean;
+  members: PlanMember[];
+  view_hash: string;
+  // 使用只读 hash 打开时为 false
+  editable: boolean;
+}
+
+export interface TodoFields {
+  parent_id?: number;
+  due?: number;
+  priority?: number;
+  tags?: string[];
+  // daily / weekdays / weekly / biweekly / monthly / yearly 或 RRULE，空字符串取消重复
+  repeat?: string;
+  // 0 取消分配
+  assignee?: number;
+}
+
+export interface TodoQuery {
+  tag?: string;
+  status?: "all" | "open" | "done";
+  due_from?: number | string;
+  due_to?: number | string;
+  // 成员 ID，或 none 只看未分配的
+  assignee?: number | "none";
+  sort?: "manual" | "priority" | "due" | "created";
 }
 
 export interface WorkPlanNewResponse {
+  // hash 可编辑，view_hash 只读
   hash: string;
+  view_hash: string;
+  // iCalendar 订阅地址
+  ics: string;
 }
 
 // GET /api/workplan/new
@@ -17,26 +77,205 @@ export function createWorkPlan() {
 }
 
 // GET /api/workplan/:hash
-export function fetchWorkPlanTodos(hash: string) {
-  return http.get<{ todos: TodoItem[] }>(`/workplan/${hash}`);
+export function fetchWorkPlanTodos(hash: string, params: TodoQuery = {}) {
+  return http.get<WorkPlanTodosResponse>(`/workplan/${hash}`, { params });
 }
 
 // POST /api/workplan/add
-export function addWorkPlanTodo(hash: string, content: string) {
-  return http.post("/workplan/add", { hash, content });
+export function addWorkPlanTodo(hash: string, content: string, fields: TodoFields = {}) {
+  return http.post<{ ok: boolean; todo: TodoItem }>("/workplan/add", { hash, content, ...fields });
 }
 
 // POST /api/workplan/done
 export function toggleWorkPlanTodo(hash: string, id: number) {
-  return http.post("/workplan/done", { hash, id });
+  // 完成重复任务时 next 为自动创建的下一次实例；skipped 为因 TODO 数量上限没有生成下一次实例的任务
+  return http.post<{ ok: boolean; next: TodoItem | null; skipped?: number[] }>("/workplan/done", { hash, id });
 }
 
 // POST /api/workplan/edit
-export function editWorkPlanTodo(hash: string, id: number, content: string) {
-  return http.post("/workplan/edit", { hash, id, content });
+export function editWorkPlanTodo(hash: string, id: number, content: string, fields: TodoFields = {}) {
+  return http.post("/workplan/edit", { hash, id, content, ...fields });
 }
 
 // POST /api/workplan/delete
 export function deleteWorkPlanTodo(hash: string, id: number) {
   return http.post("/workplan/delete", { hash, id });
 }
+
+// POST /api/workplan/move
+export function moveWorkPlanTodo(hash: string, id: number, position: number) {
+  return http.post("/workplan/move", { hash, id, position });
+}
+
+// POST /api/workplan/settings
+export function updateWorkPlanSettings(hash: string, settings: { auto_done?: boolean }) {
+  return http.post("/workplan/settings", { hash, ...settings });
+}
+
+export interface PlanEvent {
+  type: "snapshot" | "add" | "edit" | "delete" | "done" | "move" | "settings" | "members" | "timer";
+  todos?: TodoItem[];
+  ids?: number[];
+  order?: number[];
+  auto_done?: boolean;
+  members?: PlanMember[];
+  sessions?: TimeSession[];
+}
+
+// GET /api/workplan/stream/:hash（SSE，连接后先收到 snapshot）
+export function streamWorkPlan(hash: string, onEvent: (ev: PlanEvent) => void) {
+  const es = new EventSource(`${http.defaults.baseURL}/workplan/stream/${hash}`);
+  es.onmessage = (e) => onEvent(JSON.parse(e.data));
+  return es;
+}
+
+// GET /api/workplan/ics/:hash.ics，可直接在日历应用中订阅
+export function workPlanIcsUrl(hash: string) {
+  return `${http.defaults.baseURL}/workplan/ics/${hash}.ics`;
+}
+
+// POST /api/workplan/ics/:hash
+export function importWorkPlanIcs(hash: string, file: File) {
+  return importWorkPlan(hash, file, "ics");
+}
+
+export type WorkPlanFormat = "md" | "todotxt" | "csv" | "json" | "ics";
+
+export interface WorkPlanImportResponse {
+  ok: boolean;
+  imported: number;
+  skipped: number;
+  todos: TodoItem[];
+}
+
+// GET /api/workplan/export/:hash?format=，返回下载地址
+export function workPlanExportUrl(hash: string, format: WorkPlanFormat) {
+  return `${http.defaults.baseURL}/workplan/export/${hash}?format=${format}`;
+}
+
+// POST /api/workplan/import/:hash，未指定 format 时按文件扩展名推断
+export function importWorkPlan(hash: string, file: File, format?: WorkPlanFormat) {
+  const form = new FormData();
+  form.append("file", file);
+  return http.post<WorkPlanImportResponse>(`/workplan/import/${hash}`, form, { params: { format } });
+}
+
+// GET /api/workplan/history/:hash?id=
+export function fetchWorkPlanHistory(hash: string, id: number) {
+  return http.get<{ todos: TodoItem[] }>(`/workplan/history/${hash}`, { params: { id } });
+}
+
+// POST /api/workplan/member/add
+export function addWorkPlanMember(hash: string, name: string) {
+  return http.post<{ ok: boolean; member: PlanMember }>("/workplan/member/add", { hash, name });
+}
+
+// POST /api/workplan/member/edit
+export function renameWorkPlanMember(hash: string, id: number, name: string) {
+  return http.post("/workplan/member/edit", { hash, id, name });
+}
+
+// POST /api/workplan/member/delete
+export function removeWorkPlanMember(hash: string, id: number) {
+  return http.post("/workplan/member/delete", { hash, id });
+}
+
+export interface PlanActivity {
+  seq: number;
+  op: "add" | "edit" | "delete" | "done" | "reopen" | "move" | "import" | "settings" | "unassign" | "undo" | "redo";
+  ids?: number[];
+  summary?: string;
+  // undo / redo 针对的操作序号
+  ref?: number;
+  at: number;
+}
+
+export interface PlanActivityResponse {
+  activity: PlanActivity[];
+  can_undo: number;
+  can_redo: number;
+}
+
+// GET /api/workplan/activity/:hash
+export function fetchWorkPlanActivity(hash: string, params: { limit?: number; before?: number } = {}) {
+  return http.get<PlanActivityResponse>(`/workplan/activity/${hash}`, { params });
+}
+
+// POST /api/workplan/undo
+export function undoWorkPlan(hash: string, n = 1) {
+  return http.post<PlanActivityResponse & { ok: boolean }>("/workplan/undo", { hash, n });
+}
+
+// POST /api/workplan/redo
+export function redoWorkPlan(hash: string, n = 1) {
+  return http.post<PlanActivityResponse & { ok: boolean }>("/workplan/redo", { hash, n });
+}
+
+// 番茄钟设置，单位为秒；留空使用默认的 25 / 5 分钟 4 轮
+export interface PomodoroSettings {
+  work?: number;
+  break?: number;
+  rounds?: number;
+}
+
+export interface TimeSession {
+  id: number;
+  todo_id: number;
+  member?: number;
+  content: string;
+  tags?: string[];
+  start: number;
+  // 计时中为空
+  end?: number;
+  pomodoro?: PomodoroSettings;
+  seconds: number;
+}
+
+export interface TimerStatus extends TimeSession {
+  phase?: "work" | "break";
+  round?: number;
+  phase_end?: number;
+}
+
+export interface TimeTotal {
+  todo_id?: number;
+  content?: string;
+  tag?: string;
+  day?: string;
+  seconds: number;
+}
+
+export interface TimeReport {
+  from: number;
+  to: number;
+  total: number;
+  by_todo: TimeTotal[];
+  by_tag: TimeTotal[];
+  by_day: TimeTotal[];
+}
+
+// POST /api/workplan/timer/start
+export function startWorkPlanTimer(hash: string, todoId: number, member = 0, pomodoro?: PomodoroSettings) {
+  return http.post<{ ok: boolean; session: TimeSession; stopped: TimeSession | null }>(
+    "/workplan/timer/start",
+    { hash, todo_id: todoId, member, pomodoro },
+  );
+}
+
+// POST /api/workplan/timer/stop
+export function stopWorkPlanTimer(hash: string, todoId = 0, member = 0) {
+  return http.post<{ ok: boolean; session: TimeSession }>("/workplan/timer/stop", { hash, todo_id: todoId, member });
+}
+
+// GET /api/workplan/timer/:hash
+export function fetchWorkPlanTimers(hash: string) {
+  return http.get<{ timers: TimerStatus[] }>(`/workplan/timer/${hash}`);
+}
+
+// GET /api/workplan/report/:hash
+export function fetchWorkPlanReport(
+  hash: string,
+  params: { from?: number | string; to?: number | string; member?: number; tz?: string } = {},
+) {
+  return http.get<TimeReport>(`/workplan/report/${hash}`, { params });
+}